@@ -0,0 +1,190 @@
+package entry
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxZipLinkLen bounds how much of a zip member is read as a symlink target.
+const maxZipLinkLen = 4096
+
+// archiveSuffixes lists the file name suffixes listed as virtual directories.
+var archiveSuffixes = []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".iso"}
+
+func isArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitArchivePath finds the archive a path points into. It returns the
+// archive file and the slash-separated path of the member inside it, which
+// is empty when p names the archive itself.
+func splitArchivePath(p string) (archive, inner string, ok bool) {
+	return splitFilePath(p, isArchive)
+}
+
+// splitFilePath splits p at its nearest existing ancestor, which must be a
+// regular file accepted by accept, into that file and the remaining
+// slash-separated path.
+func splitFilePath(p string, accept func(string) bool) (file, inner string, ok bool) {
+	for cur := p; ; cur = filepath.Dir(cur) {
+		if fi, err := os.Stat(cur); err == nil {
+			if !fi.Mode().IsRegular() || !accept(cur) {
+				return "", "", false
+			}
+			rel, err := filepath.Rel(cur, p)
+			if err != nil {
+				return "", "", false
+			}
+			if rel == "." {
+				rel = ""
+			}
+			return cur, filepath.ToSlash(rel), true
+		}
+		if filepath.Dir(cur) == cur {
+			return "", "", false
+		}
+	}
+}
+
+// openArchive reads the member index of a zip, tar or ISO 9660 archive
+// into memory.
+// root is the listed path, which maps to inner inside the archive.
+func openArchive(root, archive, inner string) (*memFS, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMemFS(root, inner, fi.ModTime())
+	m.top.name = filepath.Base(archive)
+	lower := strings.ToLower(archive)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		err = readZip(m, f, fi.Size())
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(f); err == nil {
+			err = readTar(m, gz)
+		}
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"):
+		err = readTar(m, bzip2.NewReader(f))
+	case strings.HasSuffix(lower, ".iso"):
+		err = readISO(m, f, fi.Size())
+	default:
+		err = readTar(m, f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	if inner != "" && m.node(inner) == nil {
+		return nil, os.ErrNotExist
+	}
+	return m, nil
+}
+
+// readTar adds every member of a tar stream to m.
+func readTar(m *memFS, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := memberPath(hdr.Name)
+		if name == "" || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		m.add(name, tarNode(m, hdr))
+	}
+}
+
+func tarNode(m *memFS, hdr *tar.Header) *memNode {
+	n := &memNode{
+		mode:    hdr.FileInfo().Mode(),
+		size:    hdr.Size,
+		modTime: hdr.ModTime,
+		user:    hdr.Uname,
+		group:   hdr.Gname,
+	}
+	if n.user == "" {
+		n.user = strconv.Itoa(hdr.Uid)
+	}
+	if n.group == "" {
+		n.group = strconv.Itoa(hdr.Gid)
+	}
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		n.target = hdr.Linkname
+	case tar.TypeLink:
+		// Hard links carry no data of their own; show the linked file.
+		if target := m.node(memberPath(hdr.Linkname)); target != nil {
+			n.mode, n.size = target.mode, target.size
+		}
+	}
+	return n
+}
+
+// readZip adds every member of a zip archive to m.
+func readZip(m *memFS, r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		name := memberPath(f.Name)
+		if name == "" {
+			continue
+		}
+		n := &memNode{
+			mode:    f.Mode(),
+			size:    int64(f.UncompressedSize64),
+			modTime: f.Modified,
+		}
+		if n.mode&os.ModeSymlink != 0 {
+			n.target = readZipLink(f)
+		}
+		m.add(name, n)
+	}
+	return nil
+}
+
+func readZipLink(f *zip.File) string {
+	rc, err := f.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	target, err := io.ReadAll(io.LimitReader(rc, maxZipLinkLen))
+	if err != nil {
+		return ""
+	}
+	return string(target)
+}
+
+// memberPath normalizes an archive member name to a clean relative path.
+func memberPath(name string) string {
+	return strings.Trim(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+}
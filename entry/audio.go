@@ -0,0 +1,323 @@
+package entry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	id3HeaderLen   = 10
+	id3FooterFlag  = 0x10
+	id3v1Len       = 128
+	mpegScanLimit  = 64 << 10 // How far past the ID3 tag to look for a frame
+	oggTailLen     = 64 << 10 // How much of the file end to search for the last page
+	oggHeaderLen   = 27
+	opusSampleRate = 48000
+
+	flacStreamInfo = 0
+	flacLastBlock  = 0x80
+
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatALaw       = 0x0006
+	wavFormatMuLaw      = 0x0007
+	wavFormatExtensible = 0xfffe
+)
+
+// MPEG audio header tables, indexed by version and layer as decoded in
+// parseMPEGHeader.
+var (
+	mpegBitrates = [2][3][16]int{
+		{ // MPEG-1: layers I, II, III
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		{ // MPEG-2 and 2.5
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+	mpegSampleRates = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// mpegHeader is a decoded MPEG audio frame header.
+type mpegHeader struct {
+	version    byte // 3 = MPEG-1, 2 = MPEG-2, 0 = MPEG-2.5
+	layer      int  // 1, 2 or 3
+	bitrate    int  // kbit/s
+	sampleRate int
+	channels   int
+}
+
+// samplesPerFrame returns the number of samples each frame decodes to.
+func (h mpegHeader) samplesPerFrame() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != 3:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// sideInfoLen returns the size of the layer III side information that
+// precedes a Xing header.
+func (h mpegHeader) sideInfoLen() int {
+	switch {
+	case h.version == 3 && h.channels == 1:
+		return 17
+	case h.version == 3:
+		return 32
+	case h.channels == 1:
+		return 9
+	default:
+		return 17
+	}
+}
+
+func isMPEGSync(b []byte) bool {
+	_, ok := parseMPEGHeader(b)
+	return ok
+}
+
+func parseMPEGHeader(b []byte) (mpegHeader, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mpegHeader{}, false
+	}
+	h := mpegHeader{version: b[1] >> 3 & 3, layer: 4 - int(b[1]>>1&3)}
+	brIndex, srIndex := b[2]>>4, b[2]>>2&3
+	if h.version == 1 || h.layer == 4 || brIndex == 0 || brIndex == 15 || srIndex == 3 {
+		return mpegHeader{}, false
+	}
+	table := 0
+	if h.version != 3 {
+		table = 1
+	}
+	h.bitrate = mpegBitrates[table][h.layer-1][brIndex]
+	h.sampleRate = mpegSampleRates[h.version][srIndex]
+	h.channels = 2
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+	return h, true
+}
+
+// parseMP3 reads the first frame after any ID3v2 tag. The duration comes
+// from a Xing/Info or VBRI header when present, and otherwise from the
+// stream size at the frame's constant bitrate.
+func parseMP3(f mediaFile) mediaInfo {
+	start := int64(0)
+	hdr := make([]byte, id3HeaderLen)
+	if _, err := f.ReadAt(hdr, 0); err == nil && string(hdr[:3]) == "ID3" {
+		start = id3HeaderLen + int64(syncsafe(hdr[6:10]))
+		if hdr[5]&id3FooterFlag != 0 {
+			start += id3HeaderLen
+		}
+	}
+	buf := make([]byte, mpegScanLimit)
+	n, _ := f.ReadAt(buf, start)
+	buf = buf[:n]
+
+	var h mpegHeader
+	pos := -1
+	for i := 0; i+4 <= len(buf); i++ {
+		if fh, ok := parseMPEGHeader(buf[i:]); ok {
+			h, pos = fh, i
+			break
+		}
+	}
+	if pos < 0 {
+		return mediaInfo{}
+	}
+	audioStart := start + int64(pos)
+	audioSize := f.size - audioStart
+	tag := make([]byte, 3)
+	if _, err := f.ReadAt(tag, f.size-id3v1Len); err == nil && string(tag) == "TAG" {
+		audioSize -= id3v1Len
+	}
+
+	m := mediaInfo{sampleRate: h.sampleRate, channels: h.channels, codec: fmt.Sprintf("mp%d", h.layer)}
+	frame := buf[pos:]
+	frames, bytesTotal := xingFrames(frame, h)
+	if frames == 0 {
+		frames, bytesTotal = vbriFrames(frame)
+	}
+	if frames > 0 {
+		m.duration = durationOf(int64(frames)*int64(h.samplesPerFrame()), h.sampleRate)
+		if bytesTotal == 0 {
+			bytesTotal = audioSize
+		}
+		m.bitrate = averageBitrate(bytesTotal, m.duration)
+		return m
+	}
+	m.bitrate = h.bitrate * 1000
+	if m.bitrate > 0 {
+		m.duration = durationOf(audioSize*8, m.bitrate)
+	}
+	return m
+}
+
+// xingFrames reads the frame and byte counts of a Xing or Info header.
+func xingFrames(frame []byte, h mpegHeader) (int, int64) {
+	off := 4 + h.sideInfoLen()
+	if len(frame) < off+16 {
+		return 0, 0
+	}
+	tag := string(frame[off : off+4])
+	if tag != "Xing" && tag != "Info" {
+		return 0, 0
+	}
+	flags := binary.BigEndian.Uint32(frame[off+4:])
+	p := off + 8
+	var frames int
+	var size int64
+	if flags&1 != 0 {
+		frames = int(binary.BigEndian.Uint32(frame[p:]))
+		p += 4
+	}
+	if flags&2 != 0 && len(frame) >= p+4 {
+		size = int64(binary.BigEndian.Uint32(frame[p:]))
+	}
+	return frames, size
+}
+
+// vbriFrames reads the frame and byte counts of a Fraunhofer VBRI header,
+// which sits 32 bytes after the frame header.
+func vbriFrames(frame []byte) (int, int64) {
+	const off = 36
+	if len(frame) < off+18 || string(frame[off:off+4]) != "VBRI" {
+		return 0, 0
+	}
+	size := int64(binary.BigEndian.Uint32(frame[off+10:]))
+	frames := int(binary.BigEndian.Uint32(frame[off+14:]))
+	return frames, size
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// parseFLAC reads the STREAMINFO metadata block.
+func parseFLAC(f mediaFile) mediaInfo {
+	block := make([]byte, 4+34)
+	if _, err := f.ReadAt(block, 4); err != nil || block[0]&^flacLastBlock != flacStreamInfo {
+		return mediaInfo{}
+	}
+	info := block[4:]
+	rate := int(info[10])<<12 | int(info[11])<<4 | int(info[12])>>4
+	channels := int(info[12]>>1&7) + 1
+	samples := int64(info[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(info[14:18]))
+	m := mediaInfo{sampleRate: rate, channels: channels, codec: "flac"}
+	m.duration = durationOf(samples, rate)
+	m.bitrate = averageBitrate(f.size, m.duration)
+	return m
+}
+
+// parseWAV walks the RIFF chunks for the format and the data size,
+// seeking past chunk bodies.
+func parseWAV(f mediaFile) mediaInfo {
+	var m mediaInfo
+	var byteRate int
+	hdr := make([]byte, 8)
+	for off := int64(12); off+8 <= f.size; {
+		if _, err := f.ReadAt(hdr, off); err != nil {
+			break
+		}
+		id, size := string(hdr[:4]), int64(binary.LittleEndian.Uint32(hdr[4:]))
+		switch id {
+		case "fmt ":
+			fmtChunk := make([]byte, 16)
+			if _, err := f.ReadAt(fmtChunk, off+8); err != nil {
+				return mediaInfo{}
+			}
+			m.codec = wavCodec(binary.LittleEndian.Uint16(fmtChunk))
+			m.channels = int(binary.LittleEndian.Uint16(fmtChunk[2:]))
+			m.sampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:]))
+			byteRate = int(binary.LittleEndian.Uint32(fmtChunk[8:]))
+			m.bitrate = byteRate * 8
+		case "data":
+			size = min(size, f.size-off-8)
+			if byteRate > 0 {
+				m.duration = durationOf(size, byteRate)
+			}
+			return m
+		}
+		off += 8 + size + size%2 // Chunks are word aligned
+	}
+	return m
+}
+
+// wavCodec names the WAVE format tag of a fmt chunk.
+func wavCodec(tag uint16) string {
+	switch tag {
+	case wavFormatPCM, wavFormatExtensible:
+		return "pcm"
+	case wavFormatFloat:
+		return "float"
+	case wavFormatALaw:
+		return "alaw"
+	case wavFormatMuLaw:
+		return "ulaw"
+	default:
+		return fmt.Sprintf("0x%04x", tag)
+	}
+}
+
+// parseOgg reads the Vorbis or Opus identification header from the first
+// page and the final granule position from the last page.
+func parseOgg(f mediaFile) mediaInfo {
+	page := make([]byte, oggHeaderLen+255+64)
+	n, _ := f.ReadAt(page, 0)
+	page = page[:n]
+	if len(page) < oggHeaderLen {
+		return mediaInfo{}
+	}
+	segments := int(page[26])
+	if len(page) < oggHeaderLen+segments {
+		return mediaInfo{}
+	}
+	packet := page[oggHeaderLen+segments:]
+
+	var m mediaInfo
+	var preSkip int64
+	rate := 0
+	switch {
+	case len(packet) >= 30 && string(packet[:7]) == "\x01vorbis":
+		m.codec = "vorbis"
+		m.channels = int(packet[11])
+		m.sampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+		m.bitrate = int(int32(binary.LittleEndian.Uint32(packet[20:])))
+		rate = m.sampleRate
+	case len(packet) >= 19 && string(packet[:8]) == "OpusHead":
+		m.codec = "opus"
+		m.channels = int(packet[9])
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+		m.sampleRate = opusSampleRate
+		rate = opusSampleRate
+	default:
+		return mediaInfo{}
+	}
+
+	tailLen := min(f.size, oggTailLen)
+	tail := make([]byte, tailLen)
+	if _, err := f.ReadAt(tail, f.size-tailLen); err != nil && err != io.EOF {
+		return m
+	}
+	if i := bytes.LastIndex(tail, []byte("OggS")); i >= 0 && i+14 <= len(tail) {
+		granule := int64(binary.LittleEndian.Uint64(tail[i+6:]))
+		m.duration = durationOf(granule-preSkip, rate)
+	}
+	if m.bitrate <= 0 {
+		m.bitrate = averageBitrate(f.size, m.duration)
+	}
+	return m
+}
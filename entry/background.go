@@ -0,0 +1,149 @@
+package entry
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	ttyPath            = "/dev/tty"
+	backgroundQuery    = "\x1b]11;?\x1b\\" // OSC 11: report the background color
+	deviceAttrQuery    = "\x1b[c"          // DA1, answered by every terminal
+	deviceAttrReply    = "\x1b[?"
+	backgroundReply    = "]11;"
+	backgroundTimeout  = 100 * time.Millisecond
+	backgroundDrain    = time.Second // Wait for late replies after a timeout
+	lightLuminance     = 0.5
+	colorFgBgLightBg   = 7 // COLORFGBG backgrounds 7 and 9-15 are light
+	colorFgBgBrightMin = 9
+	colorFgBgBrightMax = 15
+)
+
+var errNoBackground = errors.New("terminal did not report its background color")
+
+// lightBackground reports whether the terminal shows a light background,
+// asking it with OSC 11 and falling back to COLORFGBG. It reports false
+// when stdout is not a terminal.
+func lightBackground() bool {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return false
+	}
+	if reply, err := queryBackground(); err == nil {
+		if rgb, ok := parseBackgroundReply(reply); ok {
+			return luminance(rgb) > lightLuminance
+		}
+	}
+	return colorFgBgLight(os.Getenv("COLORFGBG"))
+}
+
+// queryBackground asks the terminal for its background color. A device
+// attributes query follows, so terminals that ignore OSC 11 answer at
+// once instead of costing the whole timeout. Only the foreground process
+// asks, as a background one would be stopped for changing the mode.
+func queryBackground() (string, error) {
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+	if !inForeground(tty) {
+		return "", errNoBackground
+	}
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(int(tty.Fd()), state)
+	if _, err := tty.WriteString(backgroundQuery + deviceAttrQuery); err != nil {
+		return "", err
+	}
+
+	replies := make(chan string, 1)
+	go func() {
+		var reply []byte
+		buf := make([]byte, 64)
+		for {
+			n, err := tty.Read(buf)
+			reply = append(reply, buf[:n]...)
+			if err != nil || answeredDeviceAttr(reply) {
+				break
+			}
+		}
+		replies <- string(reply)
+	}()
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-time.After(backgroundTimeout):
+	}
+	// Replies arriving once the terminal is restored would reach the
+	// shell as typed input, so read them away first.
+	select {
+	case <-replies:
+	case <-time.After(backgroundDrain):
+	}
+	return "", errNoBackground
+}
+
+// answeredDeviceAttr reports whether reply ends with the answer to the
+// device attributes query, "ESC [ ? ... c".
+func answeredDeviceAttr(reply []byte) bool {
+	i := bytes.Index(reply, []byte(deviceAttrReply))
+	return i >= 0 && bytes.IndexByte(reply[i:], 'c') >= 0
+}
+
+// parseBackgroundReply extracts the color of an OSC 11 reply such as
+// "ESC ] 11 ; rgb:ffff/ffff/ffff BEL" as fractions of full intensity.
+func parseBackgroundReply(reply string) ([3]float64, bool) {
+	var rgb [3]float64
+	_, spec, ok := strings.Cut(reply, backgroundReply)
+	if !ok {
+		return rgb, false
+	}
+	if end := strings.IndexAny(spec, "\x07\x1b"); end >= 0 {
+		spec = spec[:end]
+	}
+	spec, ok = strings.CutPrefix(spec, "rgb:")
+	if !ok {
+		spec, ok = strings.CutPrefix(spec, "rgba:")
+	}
+	parts := strings.Split(spec, "/")
+	if !ok || len(parts) < len(rgb) {
+		return rgb, false
+	}
+	for i := range rgb {
+		digits := len(parts[i])
+		n, err := strconv.ParseUint(parts[i], 16, 16)
+		if err != nil || digits == 0 || digits > 4 {
+			return rgb, false
+		}
+		rgb[i] = float64(n) / float64(uint64(1)<<(4*digits)-1)
+	}
+	return rgb, true
+}
+
+// luminance returns the relative brightness of a color, weighting green
+// the most as the eye does.
+func luminance(rgb [3]float64) float64 {
+	return 0.2126*rgb[0] + 0.7152*rgb[1] + 0.0722*rgb[2]
+}
+
+// colorFgBgLight reports whether COLORFGBG, set by rxvt, Konsole and
+// others as "fg;bg" or "fg;default;bg", names a light background.
+func colorFgBgLight(value string) bool {
+	if value == "" {
+		return false
+	}
+	fields := strings.Split(value, ";")
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return false
+	}
+	return bg == colorFgBgLightBg || bg >= colorFgBgBrightMin && bg <= colorFgBgBrightMax
+}
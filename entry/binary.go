@@ -0,0 +1,341 @@
+package entry
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+const (
+	headerBinary   = "Binary"
+	headerArch     = "Arch"
+	headerLink     = "Link"
+	headerStripped = "Stripped"
+	headerInterp   = "Interpreter"
+	headerGo       = "Go"
+	headerModule   = "Module"
+
+	linkStatic  = "static"
+	linkDynamic = "dynamic"
+	linkShared  = "shared"
+	linkObject  = "object"
+
+	labelYes = "yes"
+	labelNo  = "no"
+
+	machoLoadDylinker = 0xe
+	elfMaxInterp      = 4096 // PATH_MAX, the longest interpreter path read
+	machoUniversal    = "universal"
+)
+
+// binaryInfo describes an executable or object file. Zero fields are unknown.
+type binaryInfo struct {
+	format    string // ELF, PE or Mach-O
+	bits      int
+	arch      string // GOARCH naming, or "+"-joined for universal binaries
+	link      string
+	stripped  bool
+	interp    string
+	goVersion string
+	module    string
+}
+
+var binaryCache = make(map[string]binaryInfo)
+
+// GOARCH names for machine types, so that listings can be compared
+// against the host and against Go's own naming of build targets.
+var (
+	elfArches = map[elf.Machine]string{
+		elf.EM_386:       "386",
+		elf.EM_X86_64:    "amd64",
+		elf.EM_ARM:       "arm",
+		elf.EM_AARCH64:   "arm64",
+		elf.EM_RISCV:     "riscv",
+		elf.EM_PPC:       "ppc",
+		elf.EM_PPC64:     "ppc64",
+		elf.EM_S390:      "s390x",
+		elf.EM_MIPS:      "mips",
+		elf.EM_LOONGARCH: "loong64",
+		elf.EM_SPARCV9:   "sparc64",
+	}
+	peArches = map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_I386:    "386",
+		pe.IMAGE_FILE_MACHINE_AMD64:   "amd64",
+		pe.IMAGE_FILE_MACHINE_ARMNT:   "arm",
+		pe.IMAGE_FILE_MACHINE_ARM64:   "arm64",
+		pe.IMAGE_FILE_MACHINE_RISCV64: "riscv64",
+	}
+	machoArches = map[macho.Cpu]string{
+		macho.Cpu386:   "386",
+		macho.CpuAmd64: "amd64",
+		macho.CpuArm:   "arm",
+		macho.CpuArm64: "arm64",
+		macho.CpuPpc:   "ppc",
+		macho.CpuPpc64: "ppc64",
+	}
+)
+
+func binaryColumns() []column {
+	return []column{
+		{header: headerBinary, value: func(e Entry) string {
+			b := probeBinary(e)
+			switch {
+			case b.format == "":
+				return color.placeholder(placeholderField)
+			case b.bits == 0:
+				return color.media(b.format + " " + machoUniversal)
+			}
+			return color.media(fmt.Sprintf("%s %d-bit", b.format, b.bits))
+		}},
+		{header: headerArch, value: func(e Entry) string {
+			b := probeBinary(e)
+			if b.arch == "" {
+				return color.placeholder(placeholderField)
+			}
+			if !hasArch(b.arch, runtime.GOARCH) {
+				return color.warning(b.arch) // Will not run natively here
+			}
+			return color.media(b.arch)
+		}},
+		{header: headerLink, value: func(e Entry) string {
+			return textField(probeBinary(e).link, color.media)
+		}},
+		{header: headerStripped, value: func(e Entry) string {
+			b := probeBinary(e)
+			switch {
+			case b.format == "":
+				return color.placeholder(placeholderField)
+			case b.stripped:
+				return color.media(labelYes)
+			}
+			return color.media(labelNo)
+		}},
+		{header: headerInterp, optional: true, value: func(e Entry) string {
+			return textField(probeBinary(e).interp, color.media)
+		}},
+		{header: headerGo, optional: true, value: func(e Entry) string {
+			return textField(probeBinary(e).goVersion, color.media)
+		}},
+		{header: headerModule, optional: true, value: func(e Entry) string {
+			return textField(probeBinary(e).module, color.media)
+		}},
+	}
+}
+
+// hasArch reports whether arch, which lists every architecture
+// of a universal binary, includes goarch.
+func hasArch(arch, goarch string) bool {
+	for a := range strings.SplitSeq(arch, "+") {
+		if a == goarch {
+			return true
+		}
+	}
+	return false
+}
+
+// probeBinary inspects ELF, PE and Mach-O files, identified by their
+// magic numbers. Results are cached per path.
+func probeBinary(e Entry) binaryInfo {
+	if b, ok := binaryCache[e.path]; ok {
+		return b
+	}
+	var b binaryInfo
+	if f, err := openEntry(e); err == nil {
+		b = parseBinary(f)
+		f.Close()
+	}
+	binaryCache[e.path] = b
+	return b
+}
+
+func parseBinary(f *os.File) binaryInfo {
+	magic := make([]byte, 4)
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return binaryInfo{}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return binaryInfo{}
+	}
+	var b binaryInfo
+	switch m := binary.BigEndian.Uint32(magic); {
+	case string(magic) == elf.ELFMAG:
+		b = parseELF(f, info.Size())
+	case string(magic[:2]) == "MZ":
+		b = parsePE(f)
+	case m == macho.MagicFat:
+		b = parseFatMachO(f)
+	case m == macho.Magic32, m == macho.Magic64,
+		binary.LittleEndian.Uint32(magic) == macho.Magic32,
+		binary.LittleEndian.Uint32(magic) == macho.Magic64:
+		b = parseMachO(f)
+	default:
+		return binaryInfo{}
+	}
+	if b.format != "" {
+		if info, err := buildinfo.Read(f); err == nil {
+			b.goVersion, b.module = info.GoVersion, info.Main.Path
+		}
+	}
+	return b
+}
+
+// parseELF describes an ELF file of the given size. The interpreter path
+// is read only when its length is plausible and within the file.
+func parseELF(r io.ReaderAt, size int64) binaryInfo {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return binaryInfo{}
+	}
+	defer f.Close()
+	b := binaryInfo{format: "ELF", bits: 32, stripped: f.Section(".symtab") == nil}
+	if f.Class == elf.ELFCLASS64 {
+		b.bits = 64
+	}
+	b.arch = elfArches[f.Machine]
+	switch {
+	case b.arch == "":
+		b.arch = strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+	case b.arch == "riscv", f.Machine == elf.EM_MIPS && b.bits == 64:
+		b.arch += fmt.Sprint(b.bits)
+	}
+	if f.Data == elf.ELFDATA2LSB && (f.Machine == elf.EM_PPC64 || f.Machine == elf.EM_MIPS) {
+		b.arch += "le"
+	}
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_INTERP && p.Filesz <= elfMaxInterp && p.Off+p.Filesz <= uint64(size) {
+			data := make([]byte, p.Filesz)
+			if _, err := p.ReadAt(data, 0); err == nil {
+				b.interp = string(bytes.TrimRight(data, "\x00"))
+			}
+		}
+	}
+	libs, _ := f.ImportedLibraries()
+	soname, _ := f.DynString(elf.DT_SONAME)
+	switch {
+	case f.Type == elf.ET_REL:
+		b.link = linkObject
+	case f.Type == elf.ET_DYN && b.interp == "" && len(soname) > 0:
+		b.link = linkShared
+	case b.interp != "" || len(libs) > 0:
+		b.link = linkDynamic
+	default:
+		b.link = linkStatic
+	}
+	return b
+}
+
+func parsePE(r io.ReaderAt) binaryInfo {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return binaryInfo{}
+	}
+	defer f.Close()
+	b := binaryInfo{format: "PE", bits: 32, arch: peArches[f.Machine]}
+	if _, ok := f.OptionalHeader.(*pe.OptionalHeader64); ok {
+		b.bits = 64
+	}
+	if b.arch == "" {
+		b.arch = fmt.Sprintf("0x%04x", f.Machine)
+	}
+	b.stripped = f.NumberOfSymbols == 0
+	b.link = linkStatic
+	if peHasImports(f) {
+		b.link = linkDynamic
+	}
+	if f.Characteristics&pe.IMAGE_FILE_DLL != 0 {
+		b.link = linkShared
+	}
+	return b
+}
+
+// peHasImports reports whether a PE file imports from DLLs, by its import
+// data directory or, failing that, its imported symbols. ImportedLibraries
+// of debug/pe cannot tell, as it always returns nil.
+func peHasImports(f *pe.File) bool {
+	var dirs []pe.DataDirectory
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs = h.DataDirectory[:min(h.NumberOfRvaAndSizes, uint32(len(h.DataDirectory)))]
+	case *pe.OptionalHeader64:
+		dirs = h.DataDirectory[:min(h.NumberOfRvaAndSizes, uint32(len(h.DataDirectory)))]
+	}
+	if pe.IMAGE_DIRECTORY_ENTRY_IMPORT < len(dirs) {
+		dir := dirs[pe.IMAGE_DIRECTORY_ENTRY_IMPORT]
+		if dir.VirtualAddress != 0 && dir.Size != 0 {
+			return true
+		}
+	}
+	syms, _ := f.ImportedSymbols()
+	return len(syms) > 0
+}
+
+func parseMachO(r io.ReaderAt) binaryInfo {
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return binaryInfo{}
+	}
+	defer f.Close()
+	return machOInfo(f)
+}
+
+// parseFatMachO describes a universal binary by its first architecture,
+// listing all of them.
+func parseFatMachO(r io.ReaderAt) binaryInfo {
+	f, err := macho.NewFatFile(r)
+	if err != nil || len(f.Arches) == 0 {
+		return binaryInfo{}
+	}
+	defer f.Close()
+	b := machOInfo(f.Arches[0].File)
+	b.bits = 0
+	arches := make([]string, len(f.Arches))
+	for i, a := range f.Arches {
+		arches[i] = machOInfo(a.File).arch
+	}
+	b.arch = strings.Join(arches, "+")
+	return b
+}
+
+func machOInfo(f *macho.File) binaryInfo {
+	b := binaryInfo{format: "Mach-O", bits: 32, arch: machoArches[f.Cpu]}
+	if f.Magic == macho.Magic64 {
+		b.bits = 64
+	}
+	if b.arch == "" {
+		b.arch = strings.ToLower(strings.TrimPrefix(f.Cpu.String(), "Cpu"))
+	}
+	b.stripped = f.Symtab == nil || len(f.Symtab.Syms) == 0
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if len(raw) < 12 || f.ByteOrder.Uint32(raw) != machoLoadDylinker {
+			continue
+		}
+		if off := f.ByteOrder.Uint32(raw[8:]); int(off) < len(raw) {
+			b.interp = string(bytes.TrimRight(raw[off:], "\x00"))
+		}
+	}
+	libs, _ := f.ImportedLibraries()
+	if len(libs) == 0 {
+		libs, _ = f.ImportedSymbols()
+	}
+	switch {
+	case f.Type == macho.TypeObj:
+		b.link = linkObject
+	case f.Type == macho.TypeDylib:
+		b.link = linkShared
+	case b.interp != "" || len(libs) > 0:
+		b.link = linkDynamic
+	default:
+		b.link = linkStatic
+	}
+	return b
+}
@@ -0,0 +1,204 @@
+package entry
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	headerSubject = "Subject"
+	headerIssuer  = "Issuer"
+	headerExpires = "Expires"
+	headerDays    = "Days"
+	headerKey     = "Key"
+
+	labelEncrypted = "encrypted"
+	labelPrivate   = "private"
+
+	maxCertFileLen = 1 << 20
+)
+
+// certExtensions are the file extensions inspected for certificates.
+var certExtensions = []string{".pem", ".crt", ".cer", ".der"}
+
+// certInfo describes the most urgent certificate in a file, or the key it
+// holds when it has none. Zero fields are unknown.
+type certInfo struct {
+	subject  string
+	issuer   string
+	notAfter time.Time
+	key      string
+	more     int // Further certificates in a bundle
+}
+
+// daysLeft returns the whole days until expiry, negative once expired.
+func (c certInfo) daysLeft() int {
+	return int(math.Floor(time.Until(c.notAfter).Hours() / 24))
+}
+
+var certCache = make(map[string]certInfo)
+
+// certColumns are optional, as most listings with --certs hold files
+// other than certificates.
+func certColumns() []column {
+	return []column{
+		{header: headerSubject, optional: true, value: func(e Entry) string {
+			c := probeCert(e)
+			if c.subject == "" {
+				return color.placeholder(placeholderField)
+			}
+			if c.more > 0 {
+				return color.media(fmt.Sprintf("%s (+%d)", c.subject, c.more))
+			}
+			return color.media(c.subject)
+		}},
+		{header: headerIssuer, optional: true, value: func(e Entry) string {
+			return textField(probeCert(e).issuer, color.media)
+		}},
+		{header: headerExpires, optional: true, value: func(e Entry) string {
+			c := probeCert(e)
+			if c.notAfter.IsZero() {
+				return color.placeholder(placeholderField)
+			}
+			return color.modTime(formatModTime(c.notAfter))
+		}},
+		{header: headerDays, rightAlign: true, optional: true, value: func(e Entry) string {
+			c := probeCert(e)
+			if c.notAfter.IsZero() {
+				return color.placeholder(placeholderField)
+			}
+			days := c.daysLeft()
+			return color.expiry(days, fmt.Sprint(days))
+		}},
+		{header: headerKey, optional: true, value: func(e Entry) string {
+			return textField(probeCert(e).key, color.media)
+		}},
+	}
+}
+
+// probeCert parses certificates and keys from PEM or DER files with a
+// certificate extension. Results are cached per path.
+func probeCert(e Entry) certInfo {
+	if !slices.Contains(certExtensions, strings.ToLower(filepath.Ext(e.Name()))) {
+		return certInfo{}
+	}
+	if c, ok := certCache[e.path]; ok {
+		return c
+	}
+	var c certInfo
+	if f, err := openEntry(e); err == nil {
+		if data, err := io.ReadAll(io.LimitReader(f, maxCertFileLen)); err == nil {
+			c = parseCertFile(data)
+		}
+		f.Close()
+	}
+	certCache[e.path] = c
+	return c
+}
+
+func parseCertFile(data []byte) certInfo {
+	var certs []*x509.Certificate
+	var key string
+	if block, _ := pem.Decode(data); block == nil {
+		certs, _ = x509.ParseCertificates(data)
+		if len(certs) == 0 {
+			key = describePrivateKey(data)
+		}
+	} else {
+		for rest := data; ; {
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			switch {
+			case block.Type == "CERTIFICATE":
+				if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+					certs = append(certs, cert)
+				}
+			case key != "":
+			case strings.HasPrefix(block.Type, "ENCRYPTED") || block.Headers["Proc-Type"] != "":
+				key = labelEncrypted
+			case block.Type == "PUBLIC KEY":
+				if pub, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+					key = describeKey(pub)
+				}
+			case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+				key = describePrivateKey(block.Bytes)
+			}
+		}
+	}
+	if len(certs) == 0 {
+		return certInfo{key: key}
+	}
+	cert := slices.MinFunc(certs, func(a, b *x509.Certificate) int {
+		return a.NotAfter.Compare(b.NotAfter)
+	})
+	return certInfo{
+		subject:  certName(cert.Subject.CommonName, cert.Subject.Organization, cert.DNSNames, cert.Subject.String()),
+		issuer:   certName(cert.Issuer.CommonName, cert.Issuer.Organization, nil, cert.Issuer.String()),
+		notAfter: cert.NotAfter,
+		key:      describeKey(cert.PublicKey),
+		more:     len(certs) - 1,
+	}
+}
+
+// certName picks the most readable name of a subject or issuer.
+func certName(commonName string, orgs, dnsNames []string, full string) string {
+	switch {
+	case commonName != "":
+		return cleanText(commonName)
+	case len(dnsNames) > 0:
+		return cleanText(dnsNames[0])
+	case len(orgs) > 0:
+		return cleanText(orgs[0])
+	}
+	return cleanText(full)
+}
+
+// describePrivateKey parses a PKCS #8, PKCS #1 or SEC 1 private key.
+func describePrivateKey(der []byte) string {
+	var key any
+	var err error
+	if key, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(der); err != nil {
+			if key, err = x509.ParseECPrivateKey(der); err != nil {
+				return ""
+			}
+		}
+	}
+	signer, ok := key.(interface{ Public() crypto.PublicKey })
+	if !ok {
+		return ""
+	}
+	return describeKey(signer.Public()) + " " + labelPrivate
+}
+
+// describeKey names a public key's algorithm and size or curve.
+func describeKey(pub any) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	case *ecdh.PublicKey:
+		return "ECDH"
+	case *dsa.PublicKey:
+		return fmt.Sprintf("DSA %d", k.P.BitLen())
+	}
+	return ""
+}
@@ -0,0 +1,303 @@
+package entry
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	headerLanguage = "Language"
+	headerFiles    = "Files"
+	headerCode     = "Code"
+	headerComment  = "Comment"
+	headerBlank    = "Blank"
+
+	labelVendored = "vendored"
+	labelTotal    = "Total"
+)
+
+// vendoredDirs are directories of third-party or generated content, which
+// are left out of directory rollups and the language summary.
+var vendoredDirs = []string{
+	"vendor",
+	"node_modules",
+	"third_party",
+	"bower_components",
+	"Pods",
+	".git",
+	".hg",
+	".svn",
+}
+
+// lineKind classifies a source line.
+type lineKind int
+
+const (
+	lineBlank lineKind = iota
+	lineCode
+	lineComment
+)
+
+// lineCounts tallies the lines of one or more files.
+type lineCounts struct {
+	files, code, comment, blank int
+}
+
+func (c *lineCounts) add(o lineCounts) {
+	c.files += o.files
+	c.code += o.code
+	c.comment += o.comment
+	c.blank += o.blank
+}
+
+// codeTotals maps language names to line counts.
+type codeTotals map[string]lineCounts
+
+func (t codeTotals) add(o codeTotals) {
+	for lang, c := range o {
+		sum := t[lang]
+		sum.add(c)
+		t[lang] = sum
+	}
+}
+
+func (t codeTotals) sum() lineCounts {
+	var sum lineCounts
+	for _, c := range t {
+		sum.add(c)
+	}
+	return sum
+}
+
+// languages returns the language names by lines of code, most first.
+func (t codeTotals) languages() []string {
+	names := make([]string, 0, len(t))
+	for lang := range t {
+		names = append(names, lang)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(t[b].code, t[a].code), cmp.Compare(a, b))
+	})
+	return names
+}
+
+// fileCode is the language and line counts of a source file.
+type fileCode struct {
+	lang   string
+	counts lineCounts
+}
+
+var (
+	codeFileCache = make(map[string]fileCode)
+	codeDirCache  = make(map[string]codeTotals)
+)
+
+func codeColumns() []column {
+	count := func(field func(lineCounts) int) func(Entry) string {
+		return func(e Entry) string {
+			t := entryCode(e)
+			if len(t) == 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(fmt.Sprint(field(t.sum())))
+		}
+	}
+	return []column{
+		{header: headerLanguage, value: func(e Entry) string {
+			if e.IsDir() && isVendored(e.Name()) {
+				return color.placeholder(labelVendored)
+			}
+			t := entryCode(e)
+			if len(t) == 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(t.languages()[0])
+		}},
+		{header: headerCode, rightAlign: true, value: count(func(c lineCounts) int { return c.code })},
+		{header: headerComment, rightAlign: true, value: count(func(c lineCounts) int { return c.comment })},
+		{header: headerBlank, rightAlign: true, value: count(func(c lineCounts) int { return c.blank })},
+	}
+}
+
+func isVendored(name string) bool {
+	return slices.Contains(vendoredDirs, name)
+}
+
+// entryCode returns the line counts of a source file, or the rolled-up
+// counts of everything below a directory. Symlinks and vendored
+// directories count for nothing.
+func entryCode(e Entry) codeTotals {
+	switch {
+	case e.link != nil:
+		return nil
+	case e.IsDir():
+		if isVendored(e.Name()) {
+			return nil
+		}
+		return dirCode(e.path)
+	}
+	fc := probeCode(e)
+	if fc.lang == "" {
+		return nil
+	}
+	return codeTotals{fc.lang: fc.counts}
+}
+
+// dirCode sums the line counts below dir, walking it as a listing would,
+// so hidden entries are included only with -a. Results are cached per path.
+func dirCode(dir string) codeTotals {
+	if t, ok := codeDirCache[dir]; ok {
+		return t
+	}
+	codeDirCache[dir] = nil // Guards against cycles
+	t := make(codeTotals)
+	if entries, err := readEntries(dir); err == nil {
+		for _, e := range entries {
+			t.add(entryCode(e))
+		}
+	}
+	codeDirCache[dir] = t
+	return t
+}
+
+// probeCode detects the language of a file and counts its lines. Files in
+// unknown languages and binary files yield zero info. Results are cached
+// per path.
+func probeCode(e Entry) fileCode {
+	if fc, ok := codeFileCache[e.path]; ok {
+		return fc
+	}
+	var fc fileCode
+	if f, err := openEntry(e); err == nil {
+		if lang := detectLanguage(e.Name(), f); lang != nil {
+			if counts, ok := countLines(f, lang.comments); ok {
+				fc = fileCode{lang: lang.name, counts: counts}
+			}
+		}
+		f.Close()
+	}
+	codeFileCache[e.path] = fc
+	return fc
+}
+
+// countLines classifies each line of r. It reports false for binary
+// content.
+func countLines(r io.Reader, syntax commentSyntax) (lineCounts, bool) {
+	br := bufio.NewReader(r)
+	counts := lineCounts{files: 1}
+	inBlock := false
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if strings.IndexByte(line, 0) >= 0 {
+				return lineCounts{}, false
+			}
+			switch syntax.classify(line, &inBlock) {
+			case lineCode:
+				counts.code++
+			case lineComment:
+				counts.comment++
+			default:
+				counts.blank++
+			}
+		}
+		if err != nil {
+			return counts, true
+		}
+	}
+}
+
+// classify returns the kind of a line, tracking in inBlock whether the
+// line ends inside a block comment. A line with any code on it is code.
+// String literals are not recognized.
+func (s commentSyntax) classify(line string, inBlock *bool) lineKind {
+	rest := strings.TrimSpace(line)
+	if rest == "" {
+		return lineBlank
+	}
+	var code, comment bool
+	for rest != "" {
+		if *inBlock {
+			comment = true
+			i := strings.Index(rest, s.blockEnd)
+			if i < 0 {
+				break
+			}
+			*inBlock = false
+			rest = strings.TrimSpace(rest[i+len(s.blockEnd):])
+			continue
+		}
+		if s.blockStart != "" && strings.HasPrefix(rest, s.blockStart) {
+			comment, *inBlock = true, true
+			rest = rest[len(s.blockStart):]
+			continue
+		}
+		if slices.ContainsFunc(s.line, func(p string) bool { return strings.HasPrefix(rest, p) }) {
+			comment = true
+			break
+		}
+		code = true
+		i := -1
+		if s.blockStart != "" {
+			i = strings.Index(rest, s.blockStart)
+		}
+		if i < 0 {
+			break
+		}
+		rest = rest[i:]
+	}
+	switch {
+	case code:
+		return lineCode
+	case comment:
+		return lineComment
+	}
+	return lineBlank
+}
+
+// codeSummary formats the per-language footer for the listing of path.
+func codeSummary(path string) string {
+	entries, err := readEntries(path)
+	if err != nil {
+		return ""
+	}
+	totals := make(codeTotals)
+	for _, e := range entries {
+		totals.add(entryCode(e))
+	}
+	if len(totals) == 0 {
+		return ""
+	}
+
+	langs := totals.languages()
+	sum := totals.sum()
+	nameWidth := max(len(headerLanguage), len(labelTotal))
+	for _, lang := range langs {
+		nameWidth = max(nameWidth, visibleWidth(lang))
+	}
+	numWidth := max(len(headerComment), len(fmt.Sprint(sum.code)),
+		len(fmt.Sprint(sum.comment)), len(fmt.Sprint(sum.blank)))
+
+	var sb strings.Builder
+	writeLine := func(name string, files, code, comment, blank string) {
+		sb.WriteString(padToWidth(name, nameWidth, false))
+		for _, v := range []string{files, code, comment, blank} {
+			sb.WriteByte(' ')
+			sb.WriteString(padToWidth(v, numWidth, true))
+		}
+		sb.WriteByte('\n')
+	}
+	number := func(n int) string { return color.media(fmt.Sprint(n)) }
+	sb.WriteByte('\n')
+	writeLine(headerLanguage, headerFiles, headerCode, headerComment, headerBlank)
+	for _, lang := range langs {
+		c := totals[lang]
+		writeLine(color.media(lang), number(c.files), number(c.code), number(c.comment), number(c.blank))
+	}
+	writeLine(labelTotal, number(sum.files), number(sum.code), number(sum.comment), number(sum.blank))
+	return sb.String()
+}
@@ -4,11 +4,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"golang.org/x/term"
 )
 
+// Colors of the default theme.
 const (
 	colorReadPerm    = "38;5;189" // Light lavender
 	colorWritePerm   = "38;5;140" // Medium purple
@@ -24,13 +26,33 @@ const (
 	colorSizePB      = "38;5;55"  // Deep purple-blue
 	colorSizeEB      = "38;5;91"  // Rich purple
 	colorPlaceholder = "38;5;146" // Light grayed purple
+	colorCommitHash  = "38;5;139" // Grayish mauve
+	colorAuthor      = "38;5;183" // Pale violet
+	colorAdded       = "38;5;108" // Sage green
+	colorModified    = "38;5;180" // Tan
+	colorRemoved     = "38;5;132" // Dusty rose
+	colorDuration    = "38;5;151" // Pale green
+	colorMedia       = "38;5;145" // Light gray
+	colorWarning     = "38;5;174" // Light coral
+	colorExpired     = "38;5;167" // Indian red
+	colorExpiring    = "38;5;179" // Light goldenrod
 	colorTreePrefix  = "90"       // Gray
+	colorAgeNew      = "38;5;218" // Light pink
+	colorAgeOld      = "38;5;60"  // Dim purple-gray
+	colorSizeSmall   = "38;5;146" // Light grayed purple
+	colorSizeLarge   = "38;5;213" // Orchid
+)
+
+const (
 	ansiEscapePrefix = "\x1b["
 	resetCode        = "\x1b[0m"
-	maxAnsiSeqLen    = len(ansiEscapePrefix) + len(colorReadPerm) + 1 + len(resetCode)
+	maxAnsiSeqLen    = len(ansiEscapePrefix) + len(colorReadPerm) + 1 + len(resetCode) // Typical, for preallocation
+
+	expiryAlertDays = 7  // Certificates this close to expiry are shown in red
+	expiryWarnDays  = 30 // and these in yellow
 )
 
-// Default colors for file types when LS_COLORS is not set.
+// Colors for file types that LS_COLORS leaves unset.
 var fallbackColors = map[string]string{
 	typeDir:  "34", // blue
 	typeLink: "36", // cyan
@@ -38,37 +60,83 @@ var fallbackColors = map[string]string{
 	typeFile: "0",  // default
 }
 
+// Colors for detected media types with --mime, keyed by full type or by
+// top-level type. They apply to files that LS_COLORS does not match by
+// extension, so misnamed and extensionless files are colored by content.
+var mimeColors = map[string]string{
+	"image":                       "35", // magenta
+	"video":                       "35", // magenta
+	"audio":                       "36", // cyan
+	"application/zip":             "31", // red
+	"application/gzip":            "31",
+	"application/x-bzip2":         "31",
+	"application/x-xz":            "31",
+	"application/zstd":            "31",
+	"application/x-7z-compressed": "31",
+	"application/vnd.rar":         "31",
+	"application/x-tar":           "31",
+}
+
+// Values of --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// colorModes lists the values accepted by --color.
+var colorModes = []string{colorAuto, colorAlways, colorNever}
+
+// colorizer styles output. It stays disabled until ParseFlags decides
+// whether to color.
 type colorizer struct {
-	isTTY    bool
-	lsColors map[string]string
+	enabled bool
+	ls      lsColors
+	theme   palette
+	depth   colorDepth
 }
 
+// newColorizer reads colors from LS_COLORS, or from the built-in
+// database when it is unset.
 func newColorizer() colorizer {
+	spec := os.Getenv("LS_COLORS")
+	if spec == "" {
+		spec, _ = compileDircolors(strings.NewReader(builtinDircolors), "built-in database")
+	}
 	return colorizer{
-		isTTY:    term.IsTerminal(int(os.Stdout.Fd())),
-		lsColors: parseLSColors(),
+		ls:    parseLSColors(spec),
+		theme: defaultPalette,
 	}
 }
 
-func (c colorizer) disabled() bool {
-	return !c.isTTY || cfg.NoColor
-}
-
-func (c colorizer) colorCode(e Entry, fileName string) string {
-	if ext := filepath.Ext(fileName); ext != "" {
-		if color, ok := c.lsColors["*"+ext]; ok {
-			return color
-		}
+// colorEnabled decides whether to color output for a --color mode. In
+// auto mode a non-empty NO_COLOR turns color off, CLICOLOR_FORCE other
+// than "0" turns it on, CLICOLOR=0 turns it off, and otherwise output is
+// colored only on a terminal.
+func colorEnabled(mode string) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
 	}
-	fileType, _ := e.Classify()
-	if color, ok := c.lsColors[fileType]; ok {
-		return color
+	switch {
+	case os.Getenv("NO_COLOR") != "":
+		return false
+	case os.Getenv("CLICOLOR_FORCE") != "" && os.Getenv("CLICOLOR_FORCE") != "0":
+		return true
+	case os.Getenv("CLICOLOR") == "0":
+		return false
 	}
-	return fallbackColors[fileType]
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func (c colorizer) disabled() bool {
+	return !c.enabled
 }
 
 func (c colorizer) colorize(text, colorCode string) string {
-	if c.disabled() {
+	if c.disabled() || colorCode == "" {
 		return text
 	}
 	return ansiEscapePrefix + colorCode + "m" + text + resetCode
@@ -78,12 +146,32 @@ func (c colorizer) fileName(e Entry, fileName string) string {
 	if c.disabled() {
 		return fileName
 	}
-	if code := c.colorCode(e, fileName); code != "" && code != "0" {
-		return c.colorize(fileName, code)
+	if colorScale.name && !cfg.Long && !cfg.Tree && !e.IsDir() {
+		return c.gradient(fileName, c.theme.ageNew, c.theme.ageOld, ageFraction(e.ModTime()))
+	}
+	if code := c.colorCode(e, e.Name()); code != "" && code != "0" {
+		return c.ls.start(code) + fileName + c.ls.end()
 	}
 	return fileName
 }
 
+// linkTarget colors a symlink's target as the file it points to, or with
+// the LS_COLORS missing-file code when it does not exist.
+func (c colorizer) linkTarget(e Entry) string {
+	target := e.link.target
+	if c.disabled() {
+		return target
+	}
+	code := c.ls.types[typeMissing]
+	if !e.link.isBroken && e.link.targetInfo != nil {
+		code = c.colorCode(Entry{FileInfo: e.link.targetInfo, path: linkTargetPath(e)}, filepath.Base(target))
+	}
+	if code == "" || code == "0" {
+		return target
+	}
+	return c.ls.start(code) + target + c.ls.end()
+}
+
 func (c colorizer) permissions(mode os.FileMode) string {
 	if c.disabled() {
 		return mode.String()
@@ -97,13 +185,17 @@ func (c colorizer) permissions(mode os.FileMode) string {
 		var color string
 		switch ch {
 		case 'r':
-			color = colorReadPerm
+			color = c.theme.readPerm
 		case 'w':
-			color = colorWritePerm
+			color = c.theme.writePerm
 		case 'x', 's', 'S', 't', 'T':
-			color = colorExecPerm
+			color = c.theme.execPerm
 		default:
-			color = colorPlaceholder
+			color = c.theme.placeholder
+		}
+		if color == "" {
+			sb.WriteByte(ch)
+			continue
 		}
 		sb.WriteString(ansiEscapePrefix)
 		sb.WriteString(color)
@@ -114,29 +206,44 @@ func (c colorizer) permissions(mode os.FileMode) string {
 	return sb.String()
 }
 
-func (c colorizer) user(text string) string        { return c.colorize(text, colorUser) }
-func (c colorizer) group(text string) string       { return c.colorize(text, colorGroup) }
-func (c colorizer) modTime(text string) string     { return c.colorize(text, colorModTime) }
-func (c colorizer) placeholder(text string) string { return c.colorize(text, colorPlaceholder) }
-func (c colorizer) treePrefix(text string) string  { return c.colorize(text, colorTreePrefix) }
-
-func parseLSColors() map[string]string {
-	ls := os.Getenv("LS_COLORS")
-	if ls == "" {
-		return nil
+func (c colorizer) user(text string) string       { return c.colorize(text, c.theme.user) }
+func (c colorizer) group(text string) string      { return c.colorize(text, c.theme.group) }
+func (c colorizer) modTime(text string) string    { return c.colorize(text, c.theme.modTime) }
+func (c colorizer) commitHash(text string) string { return c.colorize(text, c.theme.commitHash) }
+func (c colorizer) author(text string) string     { return c.colorize(text, c.theme.author) }
+func (c colorizer) layerChange(change byte, text string) string {
+	switch change {
+	case changeAdded:
+		return c.colorize(text, c.theme.added)
+	case changeModified:
+		return c.colorize(text, c.theme.modified)
+	default:
+		return c.colorize(text, c.theme.removed)
 	}
-	colors := make(map[string]string)
-	for _, seq := range strings.Split(ls, ":") {
-		if parts := strings.SplitN(seq, "=", 2); len(parts) == 2 {
-			colors[parts[0]] = parts[1]
-		}
+}
+
+func (c colorizer) duration(text string) string { return c.colorize(text, c.theme.duration) }
+func (c colorizer) media(text string) string    { return c.colorize(text, c.theme.media) }
+func (c colorizer) warning(text string) string  { return c.colorize(text, c.theme.warning) }
+func (c colorizer) expiry(daysLeft int, text string) string {
+	switch {
+	case daysLeft < expiryAlertDays:
+		return c.colorize(text, c.theme.expired)
+	case daysLeft < expiryWarnDays:
+		return c.colorize(text, c.theme.expiring)
+	default:
+		return c.colorize(text, c.theme.duration)
 	}
-	return colors
 }
 
+func (c colorizer) placeholder(text string) string { return c.colorize(text, c.theme.placeholder) }
+func (c colorizer) treePrefix(text string) string  { return c.colorize(text, c.theme.treePrefix) }
+
+// visibleWidth returns the number of terminal cells s occupies, skipping
+// ANSI sequences.
 func visibleWidth(s string) int {
 	if !strings.Contains(s, ansiEscapePrefix) {
-		return utf8.RuneCountInString(s)
+		return stringWidth(s)
 	}
 	count := 0
 	for i := 0; i < len(s); {
@@ -153,9 +260,56 @@ func visibleWidth(s string) int {
 			continue
 		}
 		// Count visible rune and advance by its byte length.
-		_, size := utf8.DecodeRuneInString(s[i:])
-		count++
+		r, size := utf8.DecodeRuneInString(s[i:])
+		count += runeWidth(r)
 		i += size
 	}
 	return count
 }
+
+func stringWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// wideRanges are the East Asian wide and fullwidth blocks and the emoji
+// blocks that terminals draw in two cells.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},   // Hangul Jamo
+	{0x2e80, 0x303e},   // CJK radicals and punctuation
+	{0x3041, 0x33ff},   // Kana and CJK symbols
+	{0x3400, 0x4dbf},   // CJK extension A
+	{0x4e00, 0x9fff},   // CJK unified ideographs
+	{0xa000, 0xa4cf},   // Yi
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe30, 0xfe4f},   // CJK compatibility forms
+	{0xff00, 0xff60},   // Fullwidth forms
+	{0xffe0, 0xffe6},   // Fullwidth signs
+	{0x1f300, 0x1f64f}, // Pictographs and emoticons
+	{0x1f680, 0x1f6ff}, // Transport and map symbols
+	{0x1f900, 0x1f9ff}, // Supplemental symbols and pictographs
+	{0x1fa70, 0x1faff}, // Symbols and pictographs extended-A
+	{0x20000, 0x3fffd}, // CJK extensions B and beyond
+}
+
+// runeWidth returns the cells a rune occupies: none for combining marks
+// and format characters, two for wide characters and one otherwise,
+// including Nerd Font icons in the private use area.
+func runeWidth(r rune) int {
+	if r < utf8.RuneSelf {
+		return 1
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r >= wide[0] && r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
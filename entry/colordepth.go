@@ -0,0 +1,216 @@
+package entry
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// colorDepth is the number of colors a terminal can show.
+type colorDepth int
+
+const (
+	depth16 colorDepth = iota
+	depth256
+	depthTrueColor
+
+	sgrExtendedColor = "5" // After 38 or 48: a 256-color index follows
+	sgrTrueColor     = "2" // After 38 or 48: red, green and blue follow
+)
+
+// colorDepthNames are the values accepted by --color-depth.
+var colorDepthNames = map[string]colorDepth{
+	"16":        depth16,
+	"256":       depth256,
+	"truecolor": depthTrueColor,
+}
+
+// trueColorPrograms are values of TERM_PROGRAM for terminals known to
+// support 24-bit color without saying so in TERM or COLORTERM.
+var trueColorPrograms = []string{"iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty"}
+
+// detectColorDepth guesses the terminal's color support from COLORTERM,
+// TERM and its terminfo-style suffixes, and hints set by some terminals.
+func detectColorDepth() colorDepth {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return depthTrueColor
+	}
+	term := os.Getenv("TERM")
+	switch {
+	case strings.HasSuffix(term, "-direct"), strings.HasSuffix(term, "-truecolor"), strings.HasSuffix(term, "-24bit"):
+		return depthTrueColor
+	case os.Getenv("WT_SESSION") != "":
+		return depthTrueColor
+	}
+	for _, program := range trueColorPrograms {
+		if os.Getenv("TERM_PROGRAM") == program {
+			return depthTrueColor
+		}
+	}
+	if strings.Contains(term, "256color") || os.Getenv("TERM_PROGRAM") == "Apple_Terminal" {
+		return depth256
+	}
+	return depth16
+}
+
+// ansi16 holds the usual RGB values of the 16 basic terminal colors.
+var ansi16 = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// cubeLevels are the component values of the 6×6×6 color cube.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// downsample rewrites the 256-color and 24-bit colors of SGR parameters
+// as the nearest colors available at depth. Other parameters are kept.
+func downsample(code string, depth colorDepth) string {
+	if depth == depthTrueColor || !strings.Contains(code, ";") {
+		return code
+	}
+	params := strings.Split(code, ";")
+	out := make([]string, 0, len(params))
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if (p != "38" && p != "48") || i+1 >= len(params) {
+			out = append(out, p)
+			continue
+		}
+		var rgb [3]int
+		switch params[i+1] {
+		case sgrExtendedColor:
+			if i+2 >= len(params) {
+				out = append(out, params[i:]...)
+				i = len(params)
+				continue
+			}
+			n, _ := strconv.Atoi(params[i+2])
+			if depth == depth256 {
+				out = append(out, params[i:i+3]...)
+				i += 2
+				continue
+			}
+			rgb = paletteRGB(n)
+			i += 2
+		case sgrTrueColor:
+			if i+4 >= len(params) {
+				out = append(out, params[i:]...)
+				i = len(params)
+				continue
+			}
+			for j := range rgb {
+				rgb[j], _ = strconv.Atoi(params[i+2+j])
+			}
+			i += 4
+		default:
+			out = append(out, p)
+			continue
+		}
+		background := p == "48"
+		if depth == depth256 {
+			out = append(out, p, sgrExtendedColor, strconv.Itoa(nearest256(rgb)))
+		} else {
+			out = append(out, strconv.Itoa(basicSGR(nearest16(rgb), background)))
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+// paletteRGB returns the RGB value of a 256-color index.
+func paletteRGB(n int) [3]int {
+	switch {
+	case n < 16:
+		return ansi16[max(n, 0)]
+	case n < 232:
+		n -= 16
+		return [3]int{cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]}
+	}
+	gray := 8 + 10*(min(n, 255)-232)
+	return [3]int{gray, gray, gray}
+}
+
+// nearest256 returns the cube or gray ramp index closest to rgb, trying
+// the cube levels on either side of each component.
+func nearest256(rgb [3]int) int {
+	var lo, hi [3]int
+	for i, v := range rgb {
+		for lo[i] < len(cubeLevels)-1 && cubeLevels[lo[i]+1] <= v {
+			lo[i]++
+		}
+		hi[i] = min(lo[i]+1, len(cubeLevels)-1)
+	}
+	avg := (rgb[0] + rgb[1] + rgb[2]) / 3
+	best := 232 + min(max((avg-8+5)/10, 0), 23) // Nearest gray
+	for _, r := range []int{lo[0], hi[0]} {
+		for _, g := range []int{lo[1], hi[1]} {
+			for _, b := range []int{lo[2], hi[2]} {
+				index := 16 + 36*r + 6*g + b
+				if colorDistance(paletteRGB(index), rgb) < colorDistance(paletteRGB(best), rgb) {
+					best = index
+				}
+			}
+		}
+	}
+	return best
+}
+
+// nearest16 returns the basic color index closest to rgb.
+func nearest16(rgb [3]int) int {
+	best := 0
+	for i, c := range ansi16 {
+		if colorDistance(c, rgb) < colorDistance(ansi16[best], rgb) {
+			best = i
+		}
+	}
+	return best
+}
+
+// basicSGR returns the SGR parameter selecting basic color n.
+func basicSGR(n int, background bool) int {
+	code := sgrFgBasic + n
+	if n >= 8 {
+		code = sgrFgBright + n - 8
+	}
+	if background {
+		code += sgrBackground
+	}
+	return code
+}
+
+// colorDistance is a squared distance weighted for how the eye perceives
+// red, green and blue differences.
+func colorDistance(a, b [3]int) int {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return 2*dr*dr + 4*dg*dg + 3*db*db
+}
+
+// downsample converts every color of a palette to depth.
+func (p palette) downsample(depth colorDepth) palette {
+	for _, field := range p.fields() {
+		*field = downsample(*field, depth)
+	}
+	return p
+}
+
+// downsample converts the type and pattern colors of LS_COLORS to depth,
+// leaving the escape sequence keys alone.
+func (ls lsColors) downsample(depth colorDepth) lsColors {
+	types := make(map[string]string, len(ls.types))
+	for key, code := range ls.types {
+		switch key {
+		case keyLeftCode, keyRightCode, keyEndCode, keyReset:
+		default:
+			code = downsample(code, depth)
+		}
+		types[key] = code
+	}
+	patterns := make([]lsPattern, len(ls.patterns))
+	for i, p := range ls.patterns {
+		p.code = downsample(p.code, depth)
+		patterns[i] = p
+	}
+	return lsColors{types: types, patterns: patterns}
+}
@@ -1,21 +1,30 @@
 package entry
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
 )
 
 // Config holds command-line configuration options for directory listing.
 type Config struct {
 	All         bool
 	Grid        bool
+	Across      bool
+	OneLine     bool
 	Long        bool
 	Header      bool
 	Recurse     bool
 	Tree        bool
 	Classify    bool
+	Icons       bool
 	Dereference bool
 	Size        bool
 	Time        bool
@@ -24,6 +33,27 @@ type Config struct {
 	Reverse     bool
 	NoColor     bool
 	NoSort      bool
+	Media       bool
+	Images      bool
+	Exif        bool
+	Docs        bool
+	Binary      bool
+	Text        bool
+	Code        bool
+	Mime        bool
+	Certs       bool
+	LastCommit  bool
+	Image       bool
+	Sort        string
+	Group       string
+	Filter      string
+	Dircolors   string
+	Theme       string
+	Color       string
+	ColorDepth  string
+	ColorScale  string
+	Layer       int
+	Width       int
 }
 
 type boolFlag struct {
@@ -37,9 +67,12 @@ func boolFlags() []boolFlag {
 	return []boolFlag{
 		{&cfg.All, "a", "all", "include hidden entries"},
 		{&cfg.Grid, "g", "grid", "display as grid (default)"},
+		{&cfg.Across, "", "across", "fill the grid across rows instead of down columns"},
+		{&cfg.OneLine, "1", "oneline", "list one entry per line (default when output is not a terminal)"},
 		{&cfg.Long, "l", "long", "detailed listing format"},
 		{&cfg.Header, "h", "header", "show a header row for long format"},
 		{&cfg.Classify, "F", "classify", "append file type indicators"},
+		{&cfg.Icons, "", "icons", "show Nerd Font icons before names"},
 		{&cfg.Recurse, "R", "recursive", "list subdirectories recursively"},
 		{&cfg.Tree, "T", "tree", "recursively display directory contents as a tree"},
 		{&cfg.Dereference, "L", "dereference", "show info for the target file, not the symlink"},
@@ -49,7 +82,50 @@ func boolFlags() []boolFlag {
 		{&cfg.Ext, "x", "extension", "sort by file extension"},
 		{&cfg.Reverse, "r", "reverse", "reverse the sorting order"},
 		{&cfg.NoSort, "U", "no-sort", "do not sort entries"},
-		{&cfg.NoColor, "", "no-color", "do not colorize output"},
+		{&cfg.Media, "m", "media", "show media duration, bitrate, sample rate and channels in long format"},
+		{&cfg.Images, "", "images", "show image dimensions, color model and frame count in long format"},
+		{&cfg.Exif, "", "exif", "show capture date, camera, orientation and GPS presence from EXIF in long format"},
+		{&cfg.Docs, "", "docs", "show page count, title, author and producer of PDF and office documents in long format"},
+		{&cfg.Binary, "", "binary", "show format, architecture, linking and Go build info of executables in long format"},
+		{&cfg.Text, "", "text", "show line count, encoding, BOM, line endings and final newline of text files in long format"},
+		{&cfg.Code, "", "code", "show language and code, comment and blank line counts in long format, with a per-language summary"},
+		{&cfg.Mime, "", "mime", "show the media type detected from file contents in long format, and color names by it"},
+		{&cfg.Certs, "", "certs", "show subject, issuer, expiry and key of certificate files in long format"},
+		{&cfg.NoColor, "", "no-color", "do not colorize output (same as --color=never)"},
+		{&cfg.LastCommit, "", "last-commit", "show date, hash and author of the last commit in long format"},
+		{&cfg.Image, "", "image", "list a docker save or OCI image tarball as its merged file system"},
+	}
+}
+
+type stringFlag struct {
+	ptr      *string
+	longName string
+	usage    string
+}
+
+func stringFlags() []stringFlag {
+	return []stringFlag{
+		{&cfg.Sort, "sort", "sort by `key`: " + strings.Join(sortKeys, ", ")},
+		{&cfg.Group, "group", "list entries in sections by `key`: " + strings.Join(groupKeys, ", ")},
+		{&cfg.Filter, "filter", "list only files whose `field=glob` matches, for field: " + strings.Join(filterFields, ", ")},
+		{&cfg.Color, "color", "colorize output `when`: " + strings.Join(colorModes, ", ") + " (default auto, honoring NO_COLOR, CLICOLOR and CLICOLOR_FORCE)"},
+		{&cfg.ColorScale, "color-scale", "color `fields` on a gradient: " + strings.Join(scaleFields, ", ") + " (age and size in long format, name by age in the grid)"},
+		{&cfg.Theme, "theme", "color long format with the theme `name` or file (" + strings.Join(slices.Sorted(maps.Keys(bundledThemes)), ", ") + ")"},
+		{&cfg.ColorDepth, "color-depth", "use `depth` colors instead of detecting them: " + strings.Join(slices.Sorted(maps.Keys(colorDepthNames)), ", ")},
+		{&cfg.Dircolors, "dircolors", "color file names from a dircolors `file` instead of LS_COLORS"},
+	}
+}
+
+type intFlag struct {
+	ptr      *int
+	longName string
+	usage    string
+}
+
+func intFlags() []intFlag {
+	return []intFlag{
+		{&cfg.Layer, "layer", "with --image, list only layer `N` (1 is the base layer)"},
+		{&cfg.Width, "width", "lay out the grid for `N` columns instead of COLUMNS or the terminal width"},
 	}
 }
 
@@ -57,14 +133,112 @@ func boolFlags() []boolFlag {
 func ParseFlags() (*flag.FlagSet, error) {
 	f := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
 	for _, bf := range boolFlags() {
+		if bf.shortName == "" {
+			f.BoolVar(bf.ptr, bf.longName, false, bf.usage)
+			continue
+		}
 		f.BoolVar(bf.ptr, bf.shortName, false, bf.usage)
 		f.BoolVar(bf.ptr, bf.longName, false, "alias for -"+bf.shortName)
 	}
+	for _, sf := range stringFlags() {
+		f.StringVar(sf.ptr, sf.longName, "", sf.usage)
+	}
+	for _, nf := range intFlags() {
+		f.IntVar(nf.ptr, nf.longName, 0, nf.usage)
+	}
 
 	args := expandShortFlags(os.Args[1:])
 	if err := f.Parse(args); err != nil {
 		return nil, err
 	}
+	if cfg.Sort != "" && !slices.Contains(sortKeys, cfg.Sort) {
+		err := fmt.Errorf("invalid sort key %q (valid: %s)", cfg.Sort, strings.Join(sortKeys, ", "))
+		fmt.Fprintln(f.Output(), err)
+		f.Usage()
+		return nil, err
+	}
+	if cfg.Group != "" && !slices.Contains(groupKeys, cfg.Group) {
+		err := fmt.Errorf("invalid group key %q (valid: %s)", cfg.Group, strings.Join(groupKeys, ", "))
+		fmt.Fprintln(f.Output(), err)
+		f.Usage()
+		return nil, err
+	}
+	if cfg.Layer != 0 && !cfg.Image {
+		err := errors.New("--layer requires --image")
+		fmt.Fprintln(f.Output(), err)
+		f.Usage()
+		return nil, err
+	}
+	if cfg.Filter != "" {
+		if err := parseFilter(cfg.Filter); err != nil {
+			fmt.Fprintln(f.Output(), err)
+			f.Usage()
+			return nil, err
+		}
+	}
+	if cfg.Width < 0 {
+		err := fmt.Errorf("invalid width %d", cfg.Width)
+		fmt.Fprintln(f.Output(), err)
+		f.Usage()
+		return nil, err
+	}
+	if cfg.Color != "" && !slices.Contains(colorModes, cfg.Color) {
+		err := fmt.Errorf("invalid color mode %q (valid: %s)", cfg.Color, strings.Join(colorModes, ", "))
+		fmt.Fprintln(f.Output(), err)
+		f.Usage()
+		return nil, err
+	}
+	if cfg.NoColor {
+		cfg.Color = colorNever
+	}
+	color.enabled = colorEnabled(cfg.Color)
+	depth, ok := colorDepthNames[cfg.ColorDepth]
+	if cfg.ColorDepth == "" {
+		depth = detectColorDepth()
+	} else if !ok {
+		err := fmt.Errorf("invalid color depth %q (valid: %s)", cfg.ColorDepth, strings.Join(slices.Sorted(maps.Keys(colorDepthNames)), ", "))
+		fmt.Fprintln(f.Output(), err)
+		f.Usage()
+		return nil, err
+	}
+	theme, err := loadTheme(cfg.Theme)
+	if err != nil {
+		fmt.Fprintln(f.Output(), err)
+		return nil, err
+	}
+	color.theme = theme
+	if cfg.Dircolors != "" {
+		spec, err := loadDircolors(cfg.Dircolors)
+		if err != nil {
+			fmt.Fprintln(f.Output(), err)
+			return nil, err
+		}
+		color.ls = parseLSColors(spec)
+	}
+	if cfg.ColorScale != "" {
+		if err := parseColorScale(cfg.ColorScale); err != nil {
+			fmt.Fprintln(f.Output(), err)
+			f.Usage()
+			return nil, err
+		}
+	}
+	color.depth = depth
+	color.theme = color.theme.downsample(depth)
+	color.ls = color.ls.downsample(depth)
+	if cfg.Icons {
+		if err := loadIcons(); err != nil {
+			fmt.Fprintln(f.Output(), err)
+			return nil, err
+		}
+	}
+	if cfg.Code {
+		cfg.Long = true
+	}
+	if !cfg.Grid && !cfg.Across && !term.IsTerminal(int(os.Stdout.Fd())) {
+		// Like ls, write one entry per line to pipes and files unless
+		// a grid is asked for with -g or --across.
+		cfg.OneLine = true
+	}
 	if !cfg.Long && !cfg.Grid {
 		cfg.Grid = true
 	}
@@ -73,12 +247,14 @@ func ParseFlags() (*flag.FlagSet, error) {
 
 // ResolvePath returns the first non-flag argument as a cleaned path,
 // or "." if none is provided. It returns an error if the path is inaccessible.
+// Operands naming a git revision or a path inside an archive switch listing
+// to a virtual tree.
 func ResolvePath(f *flag.FlagSet) (string, error) {
 	if f.NArg() == 0 {
 		return ".", nil
 	}
-	path := filepath.Clean(f.Arg(0))
-	if _, err := os.Stat(path); err != nil {
+	path, vfs, err := openOperand(f.Arg(0))
+	if err != nil {
 		if os.IsNotExist(err) {
 			return "", fmt.Errorf("%q: no such file or directory", path)
 		}
@@ -87,6 +263,9 @@ func ResolvePath(f *flag.FlagSet) (string, error) {
 		}
 		return "", fmt.Errorf("%q: %v", path, err)
 	}
+	if vfs != nil {
+		fsys = vfs
+	}
 	return path, nil
 }
 
@@ -0,0 +1,289 @@
+package entry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// dircolorsKeywords maps the type keywords of dircolors files to LS_COLORS keys.
+var dircolorsKeywords = map[string]string{
+	"NORMAL":                typeNormal,
+	"NORM":                  typeNormal,
+	"FILE":                  typeFile,
+	"RESET":                 keyReset,
+	"DIR":                   typeDir,
+	"LNK":                   typeLink,
+	"LINK":                  typeLink,
+	"SYMLINK":               typeLink,
+	"ORPHAN":                typeBrokenLink,
+	"MISSING":               typeMissing,
+	"FIFO":                  typeFifo,
+	"PIPE":                  typeFifo,
+	"SOCK":                  typeSocket,
+	"DOOR":                  typeDoor,
+	"BLK":                   typeBlockDevice,
+	"BLOCK":                 typeBlockDevice,
+	"CHR":                   typeCharDevice,
+	"CHAR":                  typeCharDevice,
+	"EXEC":                  typeExec,
+	"SETUID":                typeSetuid,
+	"SETGID":                typeSetgid,
+	"CAPABILITY":            typeCapability,
+	"STICKY_OTHER_WRITABLE": typeStickyOtherWritable,
+	"OWT":                   typeStickyOtherWritable,
+	"OTHER_WRITABLE":        typeOtherWritable,
+	"OWR":                   typeOtherWritable,
+	"STICKY":                typeSticky,
+	"MULTIHARDLINK":         typeMultiHardlink,
+	"LEFTCODE":              keyLeftCode,
+	"LEFT":                  keyLeftCode,
+	"RIGHTCODE":             keyRightCode,
+	"RIGHT":                 keyRightCode,
+	"ENDCODE":               keyEndCode,
+	"END":                   keyEndCode,
+}
+
+// dircolorsIgnored are keywords accepted for compatibility but without effect.
+var dircolorsIgnored = []string{"COLOR", "OPTIONS", "EIGHTBIT"}
+
+// builtinDircolors is the database used when LS_COLORS is unset. It holds
+// the same types and extensions as the output of GNU "dircolors -p".
+const builtinDircolors = `
+# File types
+RESET 0
+DIR 01;34
+LINK 01;36
+MULTIHARDLINK 00
+FIFO 40;33
+SOCK 01;35
+DOOR 01;35
+BLK 40;33;01
+CHR 40;33;01
+ORPHAN 40;31;01
+MISSING 00
+SETUID 37;41
+SETGID 30;43
+CAPABILITY 00
+STICKY_OTHER_WRITABLE 30;42
+OTHER_WRITABLE 34;42
+STICKY 37;44
+EXEC 01;32
+
+# Archives and compressed files
+.tar 01;31
+.tgz 01;31
+.arc 01;31
+.arj 01;31
+.taz 01;31
+.lha 01;31
+.lz4 01;31
+.lzh 01;31
+.lzma 01;31
+.tlz 01;31
+.txz 01;31
+.tzo 01;31
+.t7z 01;31
+.zip 01;31
+.z 01;31
+.dz 01;31
+.gz 01;31
+.lrz 01;31
+.lz 01;31
+.lzo 01;31
+.xz 01;31
+.zst 01;31
+.tzst 01;31
+.bz2 01;31
+.bz 01;31
+.tbz 01;31
+.tbz2 01;31
+.tz 01;31
+.deb 01;31
+.rpm 01;31
+.jar 01;31
+.war 01;31
+.ear 01;31
+.sar 01;31
+.rar 01;31
+.alz 01;31
+.ace 01;31
+.zoo 01;31
+.cpio 01;31
+.7z 01;31
+.rz 01;31
+.cab 01;31
+.wim 01;31
+.swm 01;31
+.dwm 01;31
+.esd 01;31
+
+# Images and video
+.avif 01;35
+.jpg 01;35
+.jpeg 01;35
+.mjpg 01;35
+.mjpeg 01;35
+.gif 01;35
+.bmp 01;35
+.pbm 01;35
+.pgm 01;35
+.ppm 01;35
+.tga 01;35
+.xbm 01;35
+.xpm 01;35
+.tif 01;35
+.tiff 01;35
+.png 01;35
+.svg 01;35
+.svgz 01;35
+.mng 01;35
+.pcx 01;35
+.mov 01;35
+.mpg 01;35
+.mpeg 01;35
+.m2v 01;35
+.mkv 01;35
+.webm 01;35
+.webp 01;35
+.ogm 01;35
+.mp4 01;35
+.m4v 01;35
+.mp4v 01;35
+.vob 01;35
+.qt 01;35
+.nuv 01;35
+.wmv 01;35
+.asf 01;35
+.rm 01;35
+.rmvb 01;35
+.flc 01;35
+.avi 01;35
+.fli 01;35
+.flv 01;35
+.gl 01;35
+.dl 01;35
+.xcf 01;35
+.xwd 01;35
+.yuv 01;35
+.cgm 01;35
+.emf 01;35
+.ogv 01;35
+.ogx 01;35
+
+# Audio
+.aac 00;36
+.au 00;36
+.flac 00;36
+.m4a 00;36
+.mid 00;36
+.midi 00;36
+.mka 00;36
+.mp3 00;36
+.mpc 00;36
+.ogg 00;36
+.ra 00;36
+.wav 00;36
+.oga 00;36
+.opus 00;36
+.spx 00;36
+.xspf 00;36
+
+# Backups and leftovers
+*~ 00;90
+*# 00;90
+.bak 00;90
+.old 00;90
+.orig 00;90
+.part 00;90
+.rej 00;90
+.swp 00;90
+.tmp 00;90
+.dpkg-dist 00;90
+.dpkg-old 00;90
+.ucf-dist 00;90
+.ucf-new 00;90
+.ucf-old 00;90
+.rpmnew 00;90
+.rpmorig 00;90
+.rpmsave 00;90
+`
+
+// loadDircolors compiles a dircolors file, such as the output of
+// "dircolors -p", into an LS_COLORS value.
+func loadDircolors(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return compileDircolors(f, name)
+}
+
+// compileDircolors converts the dircolors format to LS_COLORS. Statements
+// after a run of TERM or COLORTERM lines apply only when one of their globs
+// matches the environment, as with the dircolors command.
+func compileDircolors(r io.Reader, name string) (string, error) {
+	term, colorTerm := os.Getenv("TERM"), os.Getenv("COLORTERM")
+	var items []string
+	applies, inTermRun := true, false
+	sc := bufio.NewScanner(r)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		fields := strings.Fields(stripDircolorsComment(sc.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return "", fmt.Errorf("%s:%d: invalid line; expected KEYWORD VALUE", name, lineNo)
+		}
+		key, value := fields[0], fields[1]
+		upper := strings.ToUpper(key)
+		if upper == "TERM" || upper == "COLORTERM" {
+			if !inTermRun {
+				applies, inTermRun = false, true
+			}
+			env := term
+			if upper == "COLORTERM" {
+				env = colorTerm
+			}
+			if ok, _ := path.Match(value, env); ok {
+				applies = true
+			}
+			continue
+		}
+		inTermRun = false
+		if !applies {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(key, "."):
+			items = append(items, "*"+key+"="+value)
+		case strings.HasPrefix(key, "*"):
+			items = append(items, key+"="+value)
+		case dircolorsKeywords[upper] != "":
+			items = append(items, dircolorsKeywords[upper]+"="+value)
+		case slices.Contains(dircolorsIgnored, upper):
+		default:
+			return "", fmt.Errorf("%s:%d: unrecognized keyword %s", name, lineNo, key)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(items, ":"), nil
+}
+
+// stripDircolorsComment removes a comment, which starts with a '#' at the
+// beginning of a line or after white space, so "*#" remains a pattern.
+func stripDircolorsComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,209 @@
+package entry
+
+import (
+	"archive/zip"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerLength   = "Length"
+	headerTitle    = "Title"
+	headerDocOwner = "Author"
+	headerProducer = "Producer"
+
+	unitPage  = "page"
+	unitSheet = "sheet"
+	unitSlide = "slide"
+
+	utf8BOM        = "\xef\xbb\xbf"
+	maxDocTextLen  = 40
+	maxDocXMLBytes = 4 << 20 // Largest metadata part read from a zip package
+	odfMimePrefix  = "application/vnd.oasis.opendocument."
+)
+
+// docInfo holds document metadata. Zero fields are unknown.
+type docInfo struct {
+	title    string
+	author   string
+	producer string
+	count    int
+	unit     string // What count counts: pages, sheets or slides
+}
+
+var docCache = make(map[string]docInfo)
+
+func docColumns() []column {
+	return []column{
+		{header: headerLength, rightAlign: true, value: func(e Entry) string {
+			d := probeDoc(e)
+			if d.count <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			unit := d.unit
+			if d.count != 1 {
+				unit += "s"
+			}
+			return color.media(fmt.Sprintf("%d %s", d.count, unit))
+		}},
+		{header: headerTitle, value: func(e Entry) string {
+			return textField(probeDoc(e).title, color.media)
+		}},
+		{header: headerDocOwner, value: func(e Entry) string {
+			return textField(probeDoc(e).author, color.author)
+		}},
+		{header: headerProducer, value: func(e Entry) string {
+			return textField(probeDoc(e).producer, color.media)
+		}},
+	}
+}
+
+func textField(text string, colorize func(string) string) string {
+	if text == "" {
+		return color.placeholder(placeholderField)
+	}
+	return colorize(text)
+}
+
+// cleanText collapses white space and shortens text for a column.
+func cleanText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxDocTextLen {
+		s = string(r[:maxDocTextLen-1]) + "…"
+	}
+	return s
+}
+
+// probeDoc reads the metadata of a PDF, Office Open XML or OpenDocument
+// file. Results are cached per path.
+func probeDoc(e Entry) docInfo {
+	if d, ok := docCache[e.path]; ok {
+		return d
+	}
+	var d docInfo
+	if f, err := openEntry(e); err == nil {
+		d = parseDoc(f, e.Size())
+		f.Close()
+	}
+	docCache[e.path] = d
+	return d
+}
+
+func parseDoc(f *os.File, size int64) docInfo {
+	magic := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, magic)
+	magic = magic[:n]
+	switch {
+	case hasMagic(magic, 0, "%PDF-"):
+		return parsePDF(f, size)
+	case hasMagic(magic, 0, "PK\x03\x04"):
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return docInfo{}
+		}
+		return parsePackage(zr)
+	}
+	return docInfo{}
+}
+
+// Office Open XML parts. Element names match in any namespace.
+type (
+	ooxmlCore struct {
+		Title   string `xml:"title"`
+		Creator string `xml:"creator"`
+	}
+	ooxmlApp struct {
+		Application string `xml:"Application"`
+		Pages       string `xml:"Pages"`
+		Slides      string `xml:"Slides"`
+	}
+	ooxmlWorkbook struct {
+		Sheets []struct{} `xml:"sheets>sheet"`
+	}
+	odfMeta struct {
+		Title          string `xml:"meta>title"`
+		Creator        string `xml:"meta>creator"`
+		InitialCreator string `xml:"meta>initial-creator"`
+		Generator      string `xml:"meta>generator"`
+		Stats          struct {
+			Pages  int `xml:"page-count,attr"`
+			Tables int `xml:"table-count,attr"`
+		} `xml:"meta>document-statistic"`
+	}
+)
+
+// parsePackage reads docProps of a DOCX, XLSX or PPTX file, or meta.xml
+// of an OpenDocument file. Other zip files yield zero info.
+func parsePackage(zr *zip.Reader) docInfo {
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var d docInfo
+	if f, ok := files["mimetype"]; ok {
+		data, _ := readZipPart(f)
+		kind, isODF := strings.CutPrefix(string(data), odfMimePrefix)
+		var meta odfMeta
+		if !isODF || !unmarshalPart(files["meta.xml"], &meta) {
+			return d
+		}
+		d.title, d.producer = cleanText(meta.Title), cleanText(meta.Generator)
+		d.author = cleanText(cmp.Or(meta.Creator, meta.InitialCreator))
+		switch kind {
+		case "text":
+			d.count, d.unit = meta.Stats.Pages, unitPage
+		case "spreadsheet":
+			d.count, d.unit = meta.Stats.Tables, unitSheet
+		case "presentation":
+			d.count, d.unit = meta.Stats.Pages, unitSlide
+		}
+		return d
+	}
+
+	var core ooxmlCore
+	var app ooxmlApp
+	if !unmarshalPart(files["docProps/core.xml"], &core) {
+		return d
+	}
+	unmarshalPart(files["docProps/app.xml"], &app)
+	d.title, d.author, d.producer = cleanText(core.Title), cleanText(core.Creator), cleanText(app.Application)
+	switch {
+	case files["word/document.xml"] != nil:
+		d.count, d.unit = atoi(app.Pages), unitPage
+	case files["xl/workbook.xml"] != nil:
+		var wb ooxmlWorkbook
+		unmarshalPart(files["xl/workbook.xml"], &wb)
+		d.count, d.unit = len(wb.Sheets), unitSheet
+	case files["ppt/presentation.xml"] != nil:
+		d.count, d.unit = atoi(app.Slides), unitSlide
+	}
+	return d
+}
+
+func readZipPart(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxDocXMLBytes))
+}
+
+// unmarshalPart decodes the XML part f into v, reporting success.
+func unmarshalPart(f *zip.File, v any) bool {
+	if f == nil {
+		return false
+	}
+	data, err := readZipPart(f)
+	return err == nil && xml.Unmarshal(data, v) == nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
@@ -26,7 +26,8 @@ const (
 
 var (
 	cfg   Config
-	color = newColorizer()
+	color            = newColorizer()
+	fsys  fileSystem = osFS{}
 )
 
 // Entry represents a file or directory with its metadata.
@@ -71,6 +72,9 @@ func (e Entry) DisplayName() string {
 		name = "'" + name + "'"
 	}
 	colored := color.fileName(e, name)
+	if cfg.Icons {
+		colored = color.fileName(e, entryIcon(e)) + " " + colored
+	}
 	if cfg.Classify {
 		_, symbol := e.Classify()
 		colored += symbol
@@ -83,13 +87,27 @@ func (e Entry) DisplayName() string {
 
 type symlink struct {
 	os.FileInfo
-	target   string
-	isBroken bool
+	target     string
+	targetInfo os.FileInfo // Nil when the target cannot be resolved
+	isBroken   bool
 }
 
 // PrintEntries prints entries to stdout and, if cfg.Recurse is true,
 // recurses into subdirectories.
 func PrintEntries(path string) error {
+	if p, ok := fsys.(preambler); ok {
+		fmt.Fprint(os.Stdout, p.preamble())
+	}
+	if err := printEntries(path); err != nil {
+		return err
+	}
+	if cfg.Code {
+		fmt.Fprint(os.Stdout, codeSummary(path))
+	}
+	return nil
+}
+
+func printEntries(path string) error {
 	entries, err := readEntries(path)
 	if err != nil {
 		return err
@@ -102,7 +120,7 @@ func PrintEntries(path string) error {
 		}
 	}
 
-	output, err := render(entries)
+	output, err := renderGroups(entries)
 	if err != nil {
 		return fmt.Errorf("render error: %w", err)
 	}
@@ -117,7 +135,7 @@ func PrintEntries(path string) error {
 					subDir = "./" + e.Name()
 				}
 				fmt.Printf("\n%s:\n", subDir)
-				if err := PrintEntries(subDir); err != nil {
+				if err := printEntries(subDir); err != nil {
 					return err
 				}
 			}
@@ -128,7 +146,7 @@ func PrintEntries(path string) error {
 }
 
 func readEntries(path string) ([]Entry, error) {
-	fi, err := os.Lstat(path)
+	fi, err := fsys.Lstat(path)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +162,7 @@ func readEntries(path string) ([]Entry, error) {
 	}
 
 	// Process directory case
-	dirEntries, err := readDir(path)
+	dirEntries, err := fsys.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +180,7 @@ func readEntries(path string) ([]Entry, error) {
 		}
 	}
 
-	if len(entries) > 1 && !cfg.NoSort{
+	if len(entries) > 1 && !cfg.NoSort {
 		sortEntries(entries)
 	}
 	return entries, nil
@@ -181,7 +199,7 @@ func processEntry(path string, fi os.FileInfo) (Entry, bool, error) {
 
 	// Handle symlinks
 	if fi.Mode()&os.ModeSymlink != 0 {
-		target, err := os.Readlink(path)
+		target, err := fsys.Readlink(path)
 		if err != nil {
 			// Broken symlink: can't resolve target
 			e.link = &symlink{
@@ -197,7 +215,8 @@ func processEntry(path string, fi os.FileInfo) (Entry, bool, error) {
 			isBroken: false,
 		}
 		// Stat to detect broken links
-		if targetInfo, err := os.Stat(path); err == nil {
+		if targetInfo, err := fsys.Stat(path); err == nil {
+			e.link.targetInfo = targetInfo
 			if cfg.Dereference {
 				// Replace with dereferenced info if -L is set
 				e.link.FileInfo = targetInfo
@@ -209,17 +228,10 @@ func processEntry(path string, fi os.FileInfo) (Entry, bool, error) {
 		}
 	}
 
-	return e, true, nil
-}
-
-// readDir reads directory entries, avoiding the extra sort done by os.ReadDir.
-func readDir(path string) ([]os.DirEntry, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	if !matchesFilter(e) {
+		return Entry{}, false, nil
 	}
-	defer f.Close()
-	return f.ReadDir(-1)
+	return e, true, nil
 }
 
 func render(entries []Entry) (string, error) {
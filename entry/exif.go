@@ -0,0 +1,284 @@
+package entry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	headerTaken       = "Taken"
+	headerCamera      = "Camera"
+	headerOrientation = "Orient"
+	headerGPS         = "GPS"
+
+	labelGPS = "yes"
+
+	exifTimeFormat = "2006:01:02 15:04:05"
+	exifHeader     = "Exif\x00\x00"
+	maxIFDEntries  = 1024
+
+	jpegSOI  = 0xd8
+	jpegSOS  = 0xda
+	jpegEOI  = 0xd9
+	jpegAPP1 = 0xe1
+
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitude     = 0x0004
+
+	tiffASCII = 2
+	tiffShort = 3
+	tiffLong  = 4
+)
+
+// orientations describes the EXIF orientation values.
+var orientations = map[int]string{
+	1: "normal",
+	2: "flip-h",
+	3: "180°",
+	4: "flip-v",
+	5: "transpose",
+	6: "90° cw",
+	7: "transverse",
+	8: "90° ccw",
+}
+
+// exifInfo holds the EXIF fields shown in long format. Zero fields are unknown.
+type exifInfo struct {
+	taken       time.Time
+	make        string
+	model       string
+	orientation int
+	hasGPS      bool
+}
+
+// camera returns the make and model, without repeating a make that the
+// model already starts with.
+func (x exifInfo) camera() string {
+	if x.make == "" || strings.HasPrefix(strings.ToLower(x.model), strings.ToLower(x.make)) {
+		return x.model
+	}
+	return strings.TrimSpace(x.make + " " + x.model)
+}
+
+var exifCache = make(map[string]exifInfo)
+
+func exifColumns() []column {
+	return []column{
+		{header: headerTaken, value: func(e Entry) string {
+			x := probeEXIF(e)
+			if x.taken.IsZero() {
+				return color.placeholder(placeholderField)
+			}
+			return color.modTime(formatModTime(x.taken))
+		}},
+		{header: headerCamera, value: func(e Entry) string {
+			camera := probeEXIF(e).camera()
+			if camera == "" {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(camera)
+		}},
+		{header: headerOrientation, value: func(e Entry) string {
+			o, ok := orientations[probeEXIF(e).orientation]
+			if !ok {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(o)
+		}},
+		{header: headerGPS, value: func(e Entry) string {
+			if !probeEXIF(e).hasGPS {
+				return color.placeholder(placeholderField)
+			}
+			return color.warning(labelGPS)
+		}},
+	}
+}
+
+// probeEXIF reads the EXIF data of a JPEG or TIFF file. Results are cached
+// per path.
+func probeEXIF(e Entry) exifInfo {
+	if x, ok := exifCache[e.path]; ok {
+		return x
+	}
+	var x exifInfo
+	if f, err := openEntry(e); err == nil {
+		x = parseEXIF(f, e.Size())
+		f.Close()
+	}
+	exifCache[e.path] = x
+	return x
+}
+
+func parseEXIF(f *os.File, size int64) exifInfo {
+	magic := make([]byte, 4)
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return exifInfo{}
+	}
+	switch {
+	case magic[0] == 0xff && magic[1] == jpegSOI:
+		if tiff := jpegEXIF(f); tiff != nil {
+			return readTIFF(bytes.NewReader(tiff), int64(len(tiff)))
+		}
+	case string(magic) == "II*\x00" || string(magic) == "MM\x00*":
+		return readTIFF(f, size)
+	}
+	return exifInfo{}
+}
+
+// jpegEXIF walks the JPEG segments up to the image data and returns the
+// TIFF structure of the EXIF APP1 segment.
+func jpegEXIF(f *os.File) []byte {
+	hdr := make([]byte, 4)
+	for off := int64(2); ; {
+		if _, err := f.ReadAt(hdr, off); err != nil || hdr[0] != 0xff {
+			return nil
+		}
+		marker, size := hdr[1], int64(binary.BigEndian.Uint16(hdr[2:]))
+		if marker == jpegSOS || marker == jpegEOI || size < 2 {
+			return nil
+		}
+		if marker == jpegAPP1 {
+			seg := make([]byte, size-2)
+			if _, err := f.ReadAt(seg, off+4); err != nil {
+				return nil
+			}
+			if tiff, ok := bytes.CutPrefix(seg, []byte(exifHeader)); ok {
+				return tiff
+			}
+		}
+		off += 2 + size
+	}
+}
+
+// tiffReader reads IFDs from a TIFF structure in either byte order.
+type tiffReader struct {
+	r     io.ReaderAt
+	size  int64
+	order binary.ByteOrder
+}
+
+// ifdEntry is one 12-byte IFD entry.
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte // The 4-byte value or offset field
+}
+
+func readTIFF(r io.ReaderAt, size int64) exifInfo {
+	hdr := make([]byte, 8)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return exifInfo{}
+	}
+	t := tiffReader{r: r, size: size, order: binary.LittleEndian}
+	if string(hdr[:2]) == "MM" {
+		t.order = binary.BigEndian
+	}
+
+	var x exifInfo
+	var exifIFD, gpsIFD uint32
+	var dateTime string // DateTimeOriginal only; IFD0 DateTime is the edit time
+	for _, e := range t.ifd(t.order.Uint32(hdr[4:])) {
+		switch e.tag {
+		case tagMake:
+			x.make = t.ascii(e)
+		case tagModel:
+			x.model = t.ascii(e)
+		case tagOrientation:
+			x.orientation = int(t.uint(e))
+		case tagExifIFD:
+			exifIFD = t.uint(e)
+		case tagGPSIFD:
+			gpsIFD = t.uint(e)
+		}
+	}
+	if exifIFD != 0 {
+		for _, e := range t.ifd(exifIFD) {
+			if e.tag == tagDateTimeOriginal {
+				dateTime = t.ascii(e)
+			}
+		}
+	}
+	if gpsIFD != 0 {
+		var lat, lon bool
+		for _, e := range t.ifd(gpsIFD) {
+			lat = lat || e.tag == tagGPSLatitude && e.count > 0
+			lon = lon || e.tag == tagGPSLongitude && e.count > 0
+		}
+		x.hasGPS = lat && lon
+	}
+	if taken, err := time.ParseInLocation(exifTimeFormat, dateTime, time.Local); err == nil {
+		x.taken = taken
+	}
+	return x
+}
+
+// ifd reads the entries of the IFD at off.
+func (t tiffReader) ifd(off uint32) []ifdEntry {
+	buf := make([]byte, 2)
+	if int64(off)+2 > t.size {
+		return nil
+	}
+	if _, err := t.r.ReadAt(buf, int64(off)); err != nil {
+		return nil
+	}
+	n := min(int(t.order.Uint16(buf)), maxIFDEntries)
+	data := make([]byte, n*12)
+	if _, err := t.r.ReadAt(data, int64(off)+2); err != nil {
+		return nil
+	}
+	entries := make([]ifdEntry, n)
+	for i := range entries {
+		b := data[i*12:]
+		entries[i] = ifdEntry{
+			tag:   t.order.Uint16(b),
+			typ:   t.order.Uint16(b[2:]),
+			count: t.order.Uint32(b[4:]),
+			value: b[8:12],
+		}
+	}
+	return entries
+}
+
+func (t tiffReader) uint(e ifdEntry) uint32 {
+	switch e.typ {
+	case tiffShort:
+		return uint32(t.order.Uint16(e.value))
+	case tiffLong:
+		return t.order.Uint32(e.value)
+	}
+	return 0
+}
+
+// ascii reads a NUL-terminated string stored inline or at an offset.
+func (t tiffReader) ascii(e ifdEntry) string {
+	if e.typ != tiffASCII || e.count == 0 {
+		return ""
+	}
+	data := e.value
+	if e.count > 4 {
+		off := int64(t.order.Uint32(e.value))
+		if off+int64(e.count) > t.size {
+			return ""
+		}
+		data = make([]byte, e.count)
+		if _, err := t.r.ReadAt(data, off); err != nil {
+			return ""
+		}
+	}
+	data = data[:min(int(e.count), len(data))]
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return strings.TrimSpace(string(data))
+}
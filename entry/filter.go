@@ -0,0 +1,49 @@
+package entry
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+const filterFieldMIME = "mime"
+
+// filterFields lists the fields accepted by --filter.
+var filterFields = []string{
+	filterFieldMIME,
+}
+
+// entryFilter is a parsed --filter, or the zero value for none.
+var entryFilter struct {
+	field   string
+	pattern string
+}
+
+// parseFilter validates a FIELD=GLOB filter and stores it for matchesFilter.
+func parseFilter(s string) error {
+	field, pattern, ok := strings.Cut(s, "=")
+	if !ok || !slices.Contains(filterFields, field) {
+		return fmt.Errorf("invalid filter %q (want FIELD=GLOB, valid fields: %s)", s, strings.Join(filterFields, ", "))
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid filter pattern %q: %v", pattern, err)
+	}
+	entryFilter.field, entryFilter.pattern = field, pattern
+	return nil
+}
+
+// matchesFilter reports whether an entry passes --filter. Directories always
+// pass, so recursive listings and trees can still reach matching files.
+func matchesFilter(e Entry) bool {
+	if entryFilter.field == "" || e.IsDir() {
+		return true
+	}
+	var value string
+	switch entryFilter.field {
+	case filterFieldMIME:
+		value = detectMIME(e)
+	}
+	ok, _ := path.Match(entryFilter.pattern, value)
+	return ok
+}
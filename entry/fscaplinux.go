@@ -0,0 +1,11 @@
+//go:build linux
+
+package entry
+
+import "syscall"
+
+// hasCapability reports whether a file carries Linux file capabilities.
+func hasCapability(path string) bool {
+	n, err := syscall.Getxattr(path, "security.capability", nil)
+	return err == nil && n > 0
+}
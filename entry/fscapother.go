@@ -0,0 +1,8 @@
+//go:build !linux
+
+package entry
+
+// hasCapability reports false, as file capabilities exist only on Linux.
+func hasCapability(string) bool {
+	return false
+}
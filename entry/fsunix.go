@@ -42,3 +42,12 @@ func userGroup(e Entry) (string, string) {
 	}
 	return usr, group
 }
+
+// linkCount returns the number of hard links to an entry, or 1 when the
+// file system does not report it.
+func linkCount(e Entry) uint64 {
+	if stat, ok := e.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
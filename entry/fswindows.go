@@ -47,3 +47,8 @@ func sidToName(sid *windows.SID, cache map[string]string) string {
 	cache[sidStr] = name
 	return name
 }
+
+// linkCount returns 1, as hard link counts are not read on Windows.
+func linkCount(Entry) uint64 {
+	return 1
+}
@@ -0,0 +1,176 @@
+package entry
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerCommitDate   = "Committed"
+	headerCommitHash   = "Commit"
+	headerCommitAuthor = "Author"
+
+	// commitMarker starts each commit header line in the git log output.
+	commitMarker = "\x1e"
+	commitSep    = "\x1f"
+)
+
+// commitInfo describes the last commit that touched a path.
+type commitInfo struct {
+	hash   string
+	author string
+	time   time.Time
+}
+
+// commitIndex maps repository-relative paths under scope to the last commit
+// that touched them. It is built from a single git log pass.
+type commitIndex struct {
+	top     string // repository root
+	scope   string // directory the history pass was limited to
+	commits map[string]commitInfo
+}
+
+var (
+	commits  *commitIndex
+	realDirs = make(map[string]string)
+)
+
+func commitColumns() []column {
+	return []column{
+		{header: headerCommitDate, value: func(e Entry) string {
+			c, ok := lastCommit(e)
+			if !ok {
+				return color.placeholder(placeholderField)
+			}
+			return color.modTime(formatModTime(c.time))
+		}},
+		{header: headerCommitHash, value: func(e Entry) string {
+			c, ok := lastCommit(e)
+			if !ok {
+				return color.placeholder(placeholderField)
+			}
+			return color.commitHash(c.hash)
+		}},
+		{header: headerCommitAuthor, value: func(e Entry) string {
+			c, ok := lastCommit(e)
+			if !ok {
+				return color.placeholder(placeholderField)
+			}
+			return color.author(c.author)
+		}},
+	}
+}
+
+// lastCommit returns the last commit that touched the entry. The history
+// pass is run once for the listed directory and reused for every entry
+// (and subdirectory) below it.
+func lastCommit(e Entry) (commitInfo, bool) {
+	abs, err := filepath.Abs(e.path)
+	if err != nil {
+		return commitInfo{}, false
+	}
+	dir := realDir(filepath.Dir(abs))
+	if commits == nil || !within(dir, commits.scope) {
+		commits = buildCommitIndex(dir)
+	}
+	if commits.top == "" {
+		return commitInfo{}, false
+	}
+	rel, err := filepath.Rel(commits.top, filepath.Join(dir, filepath.Base(abs)))
+	if err != nil {
+		return commitInfo{}, false
+	}
+	c, ok := commits.commits[filepath.ToSlash(rel)]
+	return c, ok
+}
+
+// buildCommitIndex walks the history of dir once, newest first, recording
+// the first commit seen for every file and each of its parent directories.
+// A directory outside a repository yields an empty index.
+func buildCommitIndex(dir string) *commitIndex {
+	idx := &commitIndex{scope: dir, commits: make(map[string]commitInfo)}
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return idx
+	}
+	top := realDir(strings.TrimSpace(string(out)))
+
+	cmd := exec.Command("git", "-C", dir, "-c", "core.quotePath=false", "log",
+		"--format="+commitMarker+"%h"+commitSep+"%at"+commitSep+"%an",
+		"--name-only", "--no-renames", "--", ".")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return idx
+	}
+	if err := cmd.Start(); err != nil {
+		return idx
+	}
+	var current commitInfo
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		if header, ok := strings.CutPrefix(line, commitMarker); ok {
+			current = parseCommitHeader(header)
+			continue
+		}
+		// Record the file, then its parents until one is already known:
+		// a known parent was recorded together with all of its ancestors.
+		for p := line; p != "."; p = path.Dir(p) {
+			if _, seen := idx.commits[p]; seen {
+				break
+			}
+			idx.commits[p] = current
+		}
+	}
+	if sc.Err() != nil {
+		cmd.Process.Kill()
+	}
+	if err := cmd.Wait(); err != nil {
+		return idx
+	}
+	idx.top = top
+	return idx
+}
+
+func parseCommitHeader(header string) commitInfo {
+	fields := strings.SplitN(header, commitSep, 3)
+	if len(fields) != 3 {
+		return commitInfo{}
+	}
+	sec, _ := strconv.ParseInt(fields[1], 10, 64)
+	return commitInfo{
+		hash:   fields[0],
+		author: fields[2],
+		time:   time.Unix(sec, 0),
+	}
+}
+
+// realDir returns dir with symlinks resolved so that paths compare equal
+// to those reported by git. Results are cached per directory.
+func realDir(dir string) string {
+	if real, ok := realDirs[dir]; ok {
+		return real
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		real = dir
+	}
+	realDirs[dir] = real
+	return real
+}
+
+// within reports whether p is dir or lies below it.
+func within(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
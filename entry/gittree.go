@@ -0,0 +1,180 @@
+package entry
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	gitOperandPrefix = "git:"
+
+	gitModeDir       = "040000"
+	gitModeExec      = "100755"
+	gitModeSymlink   = "120000"
+	gitModeSubmodule = "160000"
+)
+
+// parseGitOperand splits an operand of the form git:REV[:PATH] into the
+// revision and the path inside it.
+func parseGitOperand(arg string) (rev, dir string, ok bool) {
+	rest, ok := strings.CutPrefix(arg, gitOperandPrefix)
+	if !ok || rest == "" {
+		return "", "", false
+	}
+	rev, dir, _ = strings.Cut(rest, ":")
+	return rev, dir, rev != ""
+}
+
+// openGitTree loads the tree of rev below dir from the repository in the
+// working directory. Paths are relative to the repository root, or to the
+// working directory when they start with "./" or "../", as in git itself.
+func openGitTree(root, rev, dir string) (*memFS, error) {
+	if dir == "." || strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../") {
+		prefix, err := runGit("", "rev-parse", "--show-prefix")
+		if err != nil {
+			return nil, err
+		}
+		dir = path.Join(strings.TrimSpace(string(prefix)), dir)
+	}
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+
+	if _, err := runGit("", "rev-parse", "--verify", "--quiet", rev+"^{tree}"); err != nil {
+		return nil, fmt.Errorf("unknown revision %q", rev)
+	}
+	var modTime time.Time
+	if out, err := runGit("", "log", "-1", "--format=%ct", rev, "--"); err == nil {
+		if sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil {
+			modTime = time.Unix(sec, 0)
+		}
+	}
+
+	args := []string{"ls-tree", "-r", "-t", "-l", "-z", "--full-tree", rev}
+	if dir != "" {
+		args = append(args, "--", dir)
+	}
+	out, err := runGit("", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMemFS(root, dir, modTime)
+	links := make(map[string][]*memNode)
+	found := dir == ""
+	for _, rec := range bytes.Split(out, []byte{0}) {
+		meta, name, ok := strings.Cut(string(rec), "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 {
+			continue
+		}
+		n := &memNode{modTime: modTime, mode: gitFileMode(fields[0])}
+		if fields[3] != "-" {
+			n.size, _ = strconv.ParseInt(fields[3], 10, 64)
+		}
+		if fields[0] == gitModeSymlink {
+			links[fields[2]] = append(links[fields[2]], n)
+		}
+		found = found || name == dir
+		m.add(name, n)
+	}
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	if err := readLinkTargets(links); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func gitFileMode(mode string) os.FileMode {
+	switch mode {
+	case gitModeDir, gitModeSubmodule:
+		return os.ModeDir | 0o755
+	case gitModeSymlink:
+		return os.ModeSymlink | 0o777
+	case gitModeExec:
+		return 0o755
+	default:
+		return 0o644
+	}
+}
+
+// readLinkTargets fills in symlink targets, keyed by blob id, with a single
+// git cat-file process.
+func readLinkTargets(links map[string][]*memNode) error {
+	if len(links) == 0 {
+		return nil
+	}
+	var ids strings.Builder
+	for id := range links {
+		ids.WriteString(id)
+		ids.WriteByte('\n')
+	}
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(ids.String())
+	out, err := cmd.Output()
+	if err != nil {
+		return gitError(err)
+	}
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			continue // "<id> missing"
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("git cat-file: bad header %q", header)
+		}
+		data := make([]byte, size+1) // content plus trailing newline
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		for _, n := range links[fields[0]] {
+			n.target = string(data[:size])
+		}
+	}
+}
+
+// runGit runs a git command in dir, or the working directory if dir is
+// empty, and returns its standard output.
+func runGit(dir string, args ...string) ([]byte, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, gitError(err)
+	}
+	return out, nil
+}
+
+// gitError turns a failed git invocation into an error carrying git's
+// own message.
+func gitError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			return errors.New(strings.TrimPrefix(msg, "fatal: "))
+		}
+	}
+	return err
+}
@@ -1,62 +1,129 @@
 package entry
 
 import (
-	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"golang.org/x/term"
 )
 
+const (
+	defaultWidth   = 80              // Grid width when nothing else sets it
+	gridSpacing    = 2               // Blank cells between grid columns
+	minColumnWidth = 1 + gridSpacing // A one-cell name and its spacing
+)
+
+// gridLayout places names in a grid, filled down columns or, with
+// --across, along rows.
+type gridLayout struct {
+	rows, cols int
+	across     bool
+	colWidths  []int
+}
+
 func renderGrid(entries []Entry) (string, error) {
 	if len(entries) == 0 {
 		return "", nil
 	}
-	maxLen := longestEntryName(entries)
-	termWidth, _ := terminalWidth()
-	cols := min(max(termWidth/(maxLen+2), 1), len(entries))
-	rows := (len(entries) + cols - 1) / cols
-	return buildGrid(entries, maxLen, cols, rows), nil
+	names := make([]string, len(entries))
+	widths := make([]int, len(entries))
+	for i, e := range entries {
+		names[i] = e.DisplayName()
+		widths[i] = visibleWidth(names[i])
+	}
+	if cfg.OneLine {
+		return buildGrid(names, widths, arrangeGrid(widths, 1, false)), nil
+	}
+	return buildGrid(names, widths, fitGrid(widths, terminalWidth(), cfg.Across)), nil
+}
+
+// terminalWidth returns the width to lay out the grid in: --width, then
+// the COLUMNS variable, then the width of the terminal on stdout.
+func terminalWidth() int {
+	if cfg.Width > 0 {
+		return cfg.Width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	return defaultWidth
 }
 
-func longestEntryName(entries []Entry) int {
-	var maxLen int
-	for _, e := range entries {
-		displayName := e.DisplayName()
-		if n := visibleWidth(displayName); n > maxLen {
-			maxLen = n
+// fitGrid returns the layout with the most columns narrower than width,
+// like GNU ls: every column is as wide as its longest name, so one long
+// name widens only its own column. A single column is used when nothing
+// else fits.
+func fitGrid(widths []int, width int, across bool) gridLayout {
+	for cols := min(max(width/minColumnWidth, 1), len(widths)); cols > 1; cols-- {
+		layout := arrangeGrid(widths, cols, across)
+		if layout.width() < width {
+			return layout
 		}
 	}
-	return maxLen
+	return arrangeGrid(widths, 1, across)
 }
 
-func terminalWidth() (int, error) {
-	width, _, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "warning: cannot get terminal size: %v\n", err)
-		return 80, nil
+// arrangeGrid lays out names of the given widths in at most cols columns.
+// Filling down columns may leave the last ones empty, so they are dropped.
+func arrangeGrid(widths []int, cols int, across bool) gridLayout {
+	rows := (len(widths) + cols - 1) / cols
+	if !across {
+		cols = (len(widths) + rows - 1) / rows
+	}
+	layout := gridLayout{rows: rows, cols: cols, across: across, colWidths: make([]int, cols)}
+	for i, w := range widths {
+		col := layout.column(i)
+		layout.colWidths[col] = max(layout.colWidths[col], w)
 	}
-	if width <= 0 {
-		return 80, nil
+	return layout
+}
+
+// column returns the column of the i-th name.
+func (g gridLayout) column(i int) int {
+	if g.across {
+		return i % g.cols
 	}
-	return width, nil
+	return i / g.rows
 }
 
-func buildGrid(entries []Entry, maxLen, cols, rows int) string {
+// index returns the position of the name at row and col, which is past
+// the last name for empty cells.
+func (g gridLayout) index(row, col int) int {
+	if g.across {
+		return row*g.cols + col
+	}
+	return col*g.rows + row
+}
+
+// width returns the cells a row of the grid occupies at most.
+func (g gridLayout) width() int {
+	total := gridSpacing * (g.cols - 1)
+	for _, w := range g.colWidths {
+		total += w
+	}
+	return total
+}
+
+func buildGrid(names []string, widths []int, g gridLayout) string {
 	var sb strings.Builder
-	sb.Grow(rows * cols * (maxLen + 2)) // Rough capacity estimate
-	for i, e := range entries {
-		displayName := e.DisplayName()
-		sb.WriteString(displayName)
-		// Pad only if not at end of row and not last item
-		if (i+1)%cols != 0 && i < len(entries)-1 {
-			// Calculate visible width of the displayName
-			visibleWidth := visibleWidth(displayName)
-			pad := maxLen - visibleWidth + 2
-			sb.WriteString(strings.Repeat(" ", pad))
-		} else {
-			sb.WriteByte('\n')
+	sb.Grow(g.rows * (g.width() + 1)) // Rough capacity estimate
+	for row := 0; row < g.rows; row++ {
+		for col := 0; col < g.cols; col++ {
+			i := g.index(row, col)
+			if i >= len(names) {
+				break
+			}
+			sb.WriteString(names[i])
+			// Pad only if another name follows on this row
+			if col+1 < g.cols && g.index(row, col+1) < len(names) {
+				sb.WriteString(strings.Repeat(" ", g.colWidths[col]-widths[i]+gridSpacing))
+			}
 		}
+		sb.WriteByte('\n')
 	}
 	return sb.String()
 }
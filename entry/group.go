@@ -0,0 +1,66 @@
+package entry
+
+import "strings"
+
+const (
+	groupKeyCamera = "camera"
+	groupKeyDate   = "date"
+
+	groupDateFormat = "2006-01-02"
+	labelNoCamera   = "Unknown camera"
+	labelNoDate     = "No capture date"
+)
+
+// groupKeys lists the keys accepted by --group.
+var groupKeys = []string{
+	groupKeyCamera,
+	groupKeyDate,
+}
+
+// groupLabel returns the section an entry is listed under.
+func groupLabel(e Entry) string {
+	switch cfg.Group {
+	case groupKeyCamera:
+		if camera := probeEXIF(e).camera(); camera != "" {
+			return camera
+		}
+		return labelNoCamera
+	case groupKeyDate:
+		if taken := probeEXIF(e).taken; !taken.IsZero() {
+			return taken.Format(groupDateFormat)
+		}
+		return labelNoDate
+	}
+	return ""
+}
+
+// renderGroups renders entries in one section per group, in the order
+// each group first appears in the sorted listing. Trees are never grouped.
+func renderGroups(entries []Entry) (string, error) {
+	if cfg.Group == "" || cfg.Tree {
+		return render(entries)
+	}
+	var labels []string
+	groups := make(map[string][]Entry)
+	for _, e := range entries {
+		label := groupLabel(e)
+		if _, ok := groups[label]; !ok {
+			labels = append(labels, label)
+		}
+		groups[label] = append(groups[label], e)
+	}
+
+	var sb strings.Builder
+	for i, label := range labels {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(label + ":\n")
+		output, err := render(groups[label])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(output)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,230 @@
+package entry
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	iconsFile = "icons" // Icon overrides in the config dir
+
+	iconDir        = "\uf07b" // nf-fa-folder
+	iconFile       = "\uf15b" // nf-fa-file
+	iconLink       = "\uf481" // nf-oct-file_symlink_file
+	iconBrokenLink = "\uf127" // nf-fa-chain_broken
+	iconExec       = "\uf489" // nf-oct-terminal
+	iconArchive    = "\uf410" // nf-oct-file_zip
+	iconImage      = "\uf1c5" // nf-fa-file_image_o
+	iconAudio      = "\uf001" // nf-fa-music
+	iconVideo      = "\uf03d" // nf-fa-video_camera
+	iconConfig     = "\uf013" // nf-fa-cog
+	iconKey        = "\uf084" // nf-fa-key
+	iconDatabase   = "\uf1c0" // nf-fa-database
+	iconGit        = "\uf1d3" // nf-fa-git
+	iconDocker     = "\uf308" // nf-linux-docker
+	iconGo         = "\ue627" // nf-seti-go
+	iconRust       = "\ue7a8" // nf-dev-rust
+	iconNode       = "\ue71e" // nf-dev-npm
+	iconBook       = "\uf02d" // nf-fa-book
+	iconMarkdown   = "\uf48a" // nf-oct-markdown
+)
+
+// iconTypeKeys maps the type names used in the icons file to Classify types.
+var iconTypeKeys = map[string]string{
+	"dir":         typeDir,
+	"file":        typeFile,
+	"link":        typeLink,
+	"broken-link": typeBrokenLink,
+	"exec":        typeExec,
+}
+
+var iconsByType = map[string]string{
+	typeDir:        iconDir,
+	typeFile:       iconFile,
+	typeLink:       iconLink,
+	typeBrokenLink: iconBrokenLink,
+	typeExec:       iconExec,
+}
+
+// iconsByName holds icons for well-known file and directory names.
+var iconsByName = map[string]string{
+	"go.mod":         iconGo,
+	"go.sum":         iconGo,
+	"go.work":        iconGo,
+	"Dockerfile":     iconDocker,
+	"Containerfile":  iconDocker,
+	".dockerignore":  iconDocker,
+	".git":           "\ue5fb", // nf-custom-folder_git
+	".github":        "\ue5fd", // nf-custom-folder_github
+	".gitignore":     iconGit,
+	".gitattributes": iconGit,
+	".gitmodules":    iconGit,
+	"node_modules":   "\ue5fa", // nf-custom-folder_npm
+	"package.json":   iconNode,
+	"Cargo.toml":     iconRust,
+	"Cargo.lock":     iconRust,
+	"Makefile":       "\uf0ad", // nf-fa-wrench
+	"LICENSE":        iconBook,
+	"README.md":      iconBook,
+}
+
+// iconsByExt holds icons for lower-case file extensions.
+var iconsByExt = map[string]string{
+	".go":     iconGo,
+	".rs":     iconRust,
+	".py":     "\ue606", // nf-seti-python
+	".js":     "\ue74e", // nf-dev-javascript_badge
+	".mjs":    "\ue74e",
+	".ts":     "\ue628", // nf-seti-typescript
+	".json":   "\ue60b", // nf-seti-json
+	".html":   "\uf13b", // nf-fa-html5
+	".css":    "\ue749", // nf-dev-css3
+	".c":      "\ue61e", // nf-custom-c
+	".h":      "\uf0fd", // nf-fa-h_square
+	".cpp":    "\ue61d", // nf-custom-cpp
+	".java":   "\ue738", // nf-dev-java
+	".rb":     "\ue21e", // nf-fae-ruby
+	".php":    "\ue73d", // nf-dev-php
+	".lua":    "\ue620", // nf-seti-lua
+	".swift":  "\ue755", // nf-dev-swift
+	".kt":     "\ue634", // nf-seti-kotlin
+	".vim":    "\ue62b", // nf-seti-vim
+	".sh":     iconExec,
+	".bash":   iconExec,
+	".zsh":    iconExec,
+	".fish":   iconExec,
+	".md":     iconMarkdown,
+	".txt":    "\uf15c", // nf-fa-file_text
+	".pdf":    "\uf1c1", // nf-fa-file_pdf_o
+	".doc":    "\uf1c2", // nf-fa-file_word_o
+	".docx":   "\uf1c2",
+	".odt":    "\uf1c2",
+	".xls":    "\uf1c3", // nf-fa-file_excel_o
+	".xlsx":   "\uf1c3",
+	".ods":    "\uf1c3",
+	".csv":    "\uf1c3",
+	".ppt":    "\uf1c4", // nf-fa-file_powerpoint_o
+	".pptx":   "\uf1c4",
+	".odp":    "\uf1c4",
+	".yml":    iconConfig,
+	".yaml":   iconConfig,
+	".toml":   iconConfig,
+	".ini":    iconConfig,
+	".conf":   iconConfig,
+	".lock":   "\uf023", // nf-fa-lock
+	".pem":    iconKey,
+	".crt":    iconKey,
+	".cer":    iconKey,
+	".key":    iconKey,
+	".db":     iconDatabase,
+	".sqlite": iconDatabase,
+	".sql":    iconDatabase,
+	".iso":    "\uf0a0", // nf-fa-hdd_o
+	".zip":    iconArchive,
+	".tar":    iconArchive,
+	".gz":     iconArchive,
+	".tgz":    iconArchive,
+	".bz2":    iconArchive,
+	".xz":     iconArchive,
+	".zst":    iconArchive,
+	".7z":     iconArchive,
+	".rar":    iconArchive,
+	".jar":    iconArchive,
+	".deb":    iconArchive,
+	".rpm":    iconArchive,
+	".png":    iconImage,
+	".jpg":    iconImage,
+	".jpeg":   iconImage,
+	".gif":    iconImage,
+	".bmp":    iconImage,
+	".webp":   iconImage,
+	".svg":    iconImage,
+	".ico":    iconImage,
+	".tif":    iconImage,
+	".tiff":   iconImage,
+	".heic":   iconImage,
+	".mp3":    iconAudio,
+	".flac":   iconAudio,
+	".wav":    iconAudio,
+	".ogg":    iconAudio,
+	".opus":   iconAudio,
+	".m4a":    iconAudio,
+	".mp4":    iconVideo,
+	".mkv":    iconVideo,
+	".webm":   iconVideo,
+	".mov":    iconVideo,
+	".avi":    iconVideo,
+}
+
+// entryIcon picks an entry's icon by its exact name, then its extension,
+// then its type. Links are always shown by type.
+func entryIcon(e Entry) string {
+	fileType, _ := e.Classify()
+	if fileType == typeLink || fileType == typeBrokenLink {
+		return iconsByType[fileType]
+	}
+	if icon, ok := iconsByName[e.Name()]; ok {
+		return icon
+	}
+	if fileType != typeDir {
+		if icon, ok := iconsByExt[strings.ToLower(filepath.Ext(e.Name()))]; ok {
+			return icon
+		}
+	}
+	return iconsByType[fileType]
+}
+
+// loadIcons applies the icons file in the config dir, if any. Each line
+// holds a key and a glyph, given literally or as U+XXXX. Keys are a type
+// (dir, file, link, broken-link, exec), an extension such as ".go", or
+// an exact file name; lines starting with '#' are comments.
+func loadIcons() error {
+	dir, err := configDir()
+	if err != nil {
+		return nil
+	}
+	name := filepath.Join(dir, iconsFile)
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: invalid line; expected KEY GLYPH", name, lineNo)
+		}
+		key, glyph := fields[0], fields[1]
+		if hex, ok := strings.CutPrefix(strings.ToUpper(glyph), "U+"); ok {
+			r, err := strconv.ParseUint(hex, 16, 32)
+			if err != nil {
+				return fmt.Errorf("%s:%d: invalid code point %q", name, lineNo, glyph)
+			}
+			glyph = string(rune(r))
+		}
+		switch {
+		case iconTypeKeys[key] != "":
+			iconsByType[iconTypeKeys[key]] = glyph
+		case strings.HasPrefix(key, "."):
+			// A leading dot names both an extension and a dotfile.
+			iconsByExt[strings.ToLower(key)] = glyph
+			iconsByName[key] = glyph
+		default:
+			iconsByName[key] = glyph
+		}
+	}
+	return sc.Err()
+}
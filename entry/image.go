@@ -0,0 +1,411 @@
+package entry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	headerLayer = "Layer"
+
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+
+	changeAdded    = '+'
+	changeModified = '~'
+	changeRemoved  = '-'
+
+	shortDigestLen = 12
+	maxImageJSON   = 8 << 20 // Largest manifest or config read into memory
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// imageFS is the file system of a container image tarball, either merged
+// from all layers or a single layer.
+type imageFS struct {
+	*memFS
+	layers     []imageLayer
+	marks      map[*memNode]layerMark
+	summarized bool
+}
+
+// imageLayer summarizes the changes made by one layer.
+type imageLayer struct {
+	id        string
+	createdBy string
+	size      int64
+	added     int
+	modified  int
+	removed   int
+}
+
+// layerMark records which layer last wrote a file and how.
+type layerMark struct {
+	layer  int
+	change byte
+}
+
+// imageManifest is an entry of a docker save manifest.json.
+type imageManifest struct {
+	Config string
+	Layers []string
+}
+
+// ociDescriptor points to a blob in an OCI image layout.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// ociManifest is an OCI image index or image manifest.
+type ociManifest struct {
+	Manifests []ociDescriptor `json:"manifests"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// imageConfig holds the parts of an image config used to describe layers.
+type imageConfig struct {
+	History []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+// imageMember locates a file inside the outer image tarball.
+type imageMember struct {
+	offset, size int64
+}
+
+// openImage reads a docker save or OCI layout tarball and builds the merged
+// file system of its layers, or only layer n (counting from 1) if n > 0.
+// root is the listed path, which maps to inner inside the image.
+func openImage(root, file, inner string, n int) (*imageFS, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	members, err := indexImage(f)
+	if err != nil {
+		return nil, err
+	}
+	layerPaths, configPath, err := imageLayers(f, members)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > len(layerPaths) {
+		return nil, fmt.Errorf("layer %d out of range (image has %d layers)", n, len(layerPaths))
+	}
+
+	img := &imageFS{
+		layers: make([]imageLayer, len(layerPaths)),
+		marks:  make(map[*memNode]layerMark),
+	}
+	describeLayers(img.layers, layerPaths, readImageConfig(f, members, configPath))
+
+	merged := newMemFS(root, inner, fi.ModTime())
+	merged.top.name = filepath.Base(file)
+	img.memFS = merged
+	for i, p := range layerPaths {
+		m, ok := members[p]
+		if !ok {
+			return nil, fmt.Errorf("layer %s not found in image", p)
+		}
+		layer := io.NewSectionReader(f, m.offset, m.size)
+		if i+1 == n {
+			single := newMemFS(root, inner, fi.ModTime())
+			single.top.name = filepath.Base(file)
+			img.memFS = single
+			if err := img.applyLayer(single, merged, layer, i); err != nil {
+				return nil, err
+			}
+			layer = io.NewSectionReader(f, m.offset, m.size)
+		}
+		if err := img.applyLayer(merged, merged, layer, i); err != nil {
+			return nil, err
+		}
+	}
+	if inner != "" && img.node(inner) == nil {
+		return nil, os.ErrNotExist
+	}
+	return img, nil
+}
+
+// indexImage records the position of every member of the outer tarball.
+// archive/tar reads headers without buffering ahead, so the file offset
+// after Next is the start of the member's data.
+// Older docker save output links duplicate layers to one another.
+func indexImage(f *os.File) (map[string]imageMember, error) {
+	members := make(map[string]imageMember)
+	links := make(map[string]string)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not an image tarball: %w", err)
+		}
+		name := memberPath(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			links[name] = memberPath(path.Join(path.Dir(name), hdr.Linkname))
+			continue
+		case tar.TypeLink:
+			links[name] = memberPath(hdr.Linkname)
+			continue
+		case tar.TypeReg:
+		default:
+			continue
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		members[name] = imageMember{offset: offset, size: hdr.Size}
+	}
+	for name, target := range links {
+		if m, ok := members[target]; ok {
+			members[name] = m
+		}
+	}
+	return members, nil
+}
+
+// imageLayers returns the layer blobs, base layer first, and the image
+// config of the first image in the tarball.
+func imageLayers(f *os.File, members map[string]imageMember) ([]string, string, error) {
+	if _, ok := members["manifest.json"]; ok {
+		var manifests []imageManifest
+		if err := readImageJSON(f, members, "manifest.json", &manifests); err != nil {
+			return nil, "", err
+		}
+		if len(manifests) == 0 {
+			return nil, "", errors.New("manifest.json lists no images")
+		}
+		layers := make([]string, len(manifests[0].Layers))
+		for i, l := range manifests[0].Layers {
+			layers[i] = memberPath(l)
+		}
+		return layers, memberPath(manifests[0].Config), nil
+	}
+	if _, ok := members["index.json"]; !ok {
+		return nil, "", errors.New("not a docker save or OCI image tarball")
+	}
+	var manifest ociManifest
+	if err := readImageJSON(f, members, "index.json", &manifest); err != nil {
+		return nil, "", err
+	}
+	// Follow nested indexes down to the first image manifest.
+	for len(manifest.Layers) == 0 {
+		if len(manifest.Manifests) == 0 {
+			return nil, "", errors.New("index.json lists no images")
+		}
+		next := blobPath(manifest.Manifests[0].Digest)
+		manifest = ociManifest{}
+		if err := readImageJSON(f, members, next, &manifest); err != nil {
+			return nil, "", err
+		}
+	}
+	layers := make([]string, len(manifest.Layers))
+	for i, l := range manifest.Layers {
+		layers[i] = blobPath(l.Digest)
+	}
+	return layers, blobPath(manifest.Config.Digest), nil
+}
+
+func readImageJSON(f *os.File, members map[string]imageMember, name string, v any) error {
+	m, ok := members[name]
+	if !ok {
+		return fmt.Errorf("%s not found in image", name)
+	}
+	if m.size > maxImageJSON {
+		return fmt.Errorf("%s too large", name)
+	}
+	if err := json.NewDecoder(io.NewSectionReader(f, m.offset, m.size)).Decode(v); err != nil {
+		return fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return nil
+}
+
+func readImageConfig(f *os.File, members map[string]imageMember, name string) imageConfig {
+	var config imageConfig
+	if name != "" {
+		_ = readImageJSON(f, members, name, &config) // Descriptions are optional
+	}
+	return config
+}
+
+// describeLayers fills in layer ids and the commands that created them.
+func describeLayers(layers []imageLayer, paths []string, config imageConfig) {
+	for i, p := range paths {
+		id := path.Base(p)
+		if id == "layer.tar" {
+			id = path.Base(path.Dir(p))
+		}
+		layers[i].id = id[:min(len(id), shortDigestLen)]
+	}
+	i := 0
+	for _, h := range config.History {
+		if h.EmptyLayer {
+			continue
+		}
+		if i >= len(layers) {
+			break
+		}
+		layers[i].createdBy = strings.TrimPrefix(h.CreatedBy, "/bin/sh -c #(nop) ")
+		i++
+	}
+}
+
+// blobPath maps a digest such as "sha256:abc" to its OCI layout path.
+func blobPath(digest string) string {
+	algo, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algo, hex)
+}
+
+// applyLayer adds the changes of layer i to dst, marking each file as added
+// or overwritten relative to lower, the merged state of the layers below.
+// When dst is lower, whiteouts delete files; otherwise they are kept as
+// removed entries.
+func (img *imageFS) applyLayer(dst, lower *memFS, r io.Reader, i int) error {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	var layer io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i+1, err)
+		}
+		defer gz.Close()
+		layer = gz
+	case bytes.HasPrefix(magic, zstdMagic):
+		return fmt.Errorf("layer %d: zstd compression is not supported", i+1)
+	}
+
+	merging := dst == lower
+	stats := &img.layers[i]
+	tr := tar.NewReader(layer)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i+1, err)
+		}
+		name := memberPath(hdr.Name)
+		if name == "" || hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		dir, base := path.Split(name)
+		switch {
+		case base == whiteoutOpaque:
+			if merging {
+				img.clearLower(dst.node(dir), i)
+			}
+		case strings.HasPrefix(base, whiteoutPrefix):
+			target := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+			if merging {
+				stats.removed++
+				dst.remove(target)
+				continue
+			}
+			n := tarNode(dst, hdr)
+			dst.add(target, n)
+			img.marks[n] = layerMark{layer: i, change: changeRemoved}
+		default:
+			old := lower.node(name)
+			n := tarNode(dst, hdr)
+			mark := layerMark{layer: i, change: changeAdded}
+			if old != nil {
+				if lowerMark, ok := img.marks[old]; ok && n.IsDir() && old.IsDir() {
+					mark = lowerMark // Repeated parent directory
+				} else if !n.IsDir() || !old.IsDir() {
+					mark.change = changeModified
+				}
+			}
+			if merging {
+				if mark.layer == i {
+					if mark.change == changeAdded {
+						stats.added++
+					} else {
+						stats.modified++
+					}
+				}
+				if n.mode.IsRegular() {
+					stats.size += n.size
+				}
+			}
+			dst.add(name, n)
+			img.marks[n] = mark
+		}
+	}
+}
+
+// clearLower removes the children of an opaque directory that came from
+// layers below layer i.
+func (img *imageFS) clearLower(dir *memNode, i int) {
+	if dir == nil {
+		return
+	}
+	for _, c := range append([]*memNode(nil), dir.children...) {
+		if mark, ok := img.marks[c]; !ok || mark.layer < i {
+			dir.removeChild(c.name)
+		}
+	}
+}
+
+// preamble describes each layer once, before the first listing.
+func (img *imageFS) preamble() string {
+	if img.summarized {
+		return ""
+	}
+	img.summarized = true
+	var sb strings.Builder
+	for i, l := range img.layers {
+		fmt.Fprintf(&sb, "%s %d %s %s %s %s\n",
+			headerLayer,
+			i+1,
+			color.commitHash(l.id),
+			padToWidth(formatSize(l.size), 6, true),
+			color.placeholder(fmt.Sprintf("%c%d %c%d %c%d",
+				changeAdded, l.added, changeModified, l.modified, changeRemoved, l.removed)),
+			l.createdBy,
+		)
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func layerColumn(img *imageFS) column {
+	return column{header: headerLayer, value: func(e Entry) string {
+		n, _ := e.Sys().(*memNode)
+		mark, ok := img.marks[n]
+		if !ok {
+			return color.placeholder(placeholderField)
+		}
+		return color.layerChange(mark.change, fmt.Sprintf("%c%d", mark.change, mark.layer+1))
+	}}
+}
@@ -0,0 +1,346 @@
+package entry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	imgcolor "image/color"
+	_ "image/gif"  // Register GIF for image.DecodeConfig
+	_ "image/jpeg" // Register JPEG for image.DecodeConfig
+	"io"
+	"os"
+)
+
+const (
+	headerDimensions = "Dimensions"
+	headerColorModel = "Color"
+	headerFrames     = "Frames"
+
+	bmpFileHeaderLen = 14
+	bmpCoreHeaderLen = 12
+
+	gifExtension  = 0x21
+	gifImage      = 0x2c
+	gifTrailer    = 0x3b
+	gifTableFlag  = 0x80
+	gifHeaderLen  = 13
+	gifImageDescr = 9
+
+	webpAlpha     = 0x10
+	webpAnimation = 0x02
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngColorTypes names the IHDR color types.
+var pngColorTypes = map[byte]string{
+	0: "Gray",
+	2: "RGB",
+	3: "Indexed",
+	4: "GrayA",
+	6: "RGBA",
+}
+
+// imageInfo holds the header fields of an image file. Zero fields are unknown.
+type imageInfo struct {
+	width  int
+	height int
+	model  string
+	frames int
+}
+
+func (i imageInfo) pixels() int { return i.width * i.height }
+
+var imageCache = make(map[string]imageInfo)
+
+func imageColumns() []column {
+	return []column{
+		{header: headerDimensions, rightAlign: true, value: func(e Entry) string {
+			i := probeImage(e)
+			if i.width <= 0 || i.height <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(fmt.Sprintf("%dx%d", i.width, i.height))
+		}},
+		{header: headerColorModel, value: func(e Entry) string {
+			i := probeImage(e)
+			if i.model == "" {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(i.model)
+		}},
+		{header: headerFrames, rightAlign: true, value: func(e Entry) string {
+			i := probeImage(e)
+			if i.frames <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(fmt.Sprint(i.frames))
+		}},
+	}
+}
+
+// probeImage reads the headers of a PNG, JPEG, GIF, BMP or WebP file.
+// Pixel data is never decoded. Results are cached per path.
+func probeImage(e Entry) imageInfo {
+	if i, ok := imageCache[e.path]; ok {
+		return i
+	}
+	var i imageInfo
+	if f, err := openEntry(e); err == nil {
+		i = parseImage(f)
+		f.Close()
+	}
+	imageCache[e.path] = i
+	return i
+}
+
+func parseImage(f *os.File) imageInfo {
+	magic := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, magic)
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, pngSignature):
+		return parsePNG(f)
+	case hasMagic(magic, 0, "BM"):
+		return parseBMP(f)
+	case hasMagic(magic, 0, "RIFF") && hasMagic(magic, 8, "WEBP"):
+		return parseWebP(f)
+	case hasMagic(magic, 0, "GIF8"):
+		i := decodeImageConfig(f)
+		i.frames = gifFrames(f)
+		return i
+	case hasMagic(magic, 0, "\xff\xd8"):
+		i := decodeImageConfig(f)
+		i.frames = 1
+		return i
+	}
+	return imageInfo{}
+}
+
+// decodeImageConfig reads dimensions and the color model through the
+// standard library decoders.
+func decodeImageConfig(f *os.File) imageInfo {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return imageInfo{}
+	}
+	conf, _, err := image.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return imageInfo{}
+	}
+	return imageInfo{width: conf.Width, height: conf.Height, model: colorModelName(conf.ColorModel)}
+}
+
+func colorModelName(m imgcolor.Model) string {
+	switch m {
+	case imgcolor.GrayModel:
+		return "Gray"
+	case imgcolor.Gray16Model:
+		return "Gray16"
+	case imgcolor.YCbCrModel:
+		return "YCbCr"
+	case imgcolor.CMYKModel:
+		return "CMYK"
+	case imgcolor.RGBAModel, imgcolor.NRGBAModel:
+		return "RGBA"
+	case imgcolor.RGBA64Model, imgcolor.NRGBA64Model:
+		return "RGBA64"
+	}
+	if p, ok := m.(imgcolor.Palette); ok {
+		if len(p) == 0 {
+			return "Indexed" // Local color tables only
+		}
+		return fmt.Sprintf("Indexed %d", len(p))
+	}
+	return ""
+}
+
+// parsePNG reads IHDR and walks the chunks before the image data for an
+// APNG animation control chunk.
+func parsePNG(f *os.File) imageInfo {
+	hdr := make([]byte, 8+13)
+	if _, err := f.ReadAt(hdr, int64(len(pngSignature))); err != nil || string(hdr[4:8]) != "IHDR" {
+		return imageInfo{}
+	}
+	ihdr := hdr[8:]
+	i := imageInfo{
+		width:  int(binary.BigEndian.Uint32(ihdr[0:])),
+		height: int(binary.BigEndian.Uint32(ihdr[4:])),
+		frames: 1,
+	}
+	if name, ok := pngColorTypes[ihdr[9]]; ok {
+		i.model = fmt.Sprintf("%s %d-bit", name, ihdr[8])
+	}
+	chunk := make([]byte, 12)
+	for off := int64(len(pngSignature)); ; {
+		if _, err := f.ReadAt(chunk, off); err != nil {
+			break
+		}
+		size, typ := int64(binary.BigEndian.Uint32(chunk)), string(chunk[4:8])
+		if typ == "IDAT" || typ == "IEND" {
+			break
+		}
+		if typ == "acTL" {
+			i.frames = int(binary.BigEndian.Uint32(chunk[8:]))
+			break
+		}
+		off += 12 + size // Length, type, data and CRC
+	}
+	return i
+}
+
+// parseBMP reads the bitmap file and info headers.
+func parseBMP(f *os.File) imageInfo {
+	hdr := make([]byte, bmpFileHeaderLen+16)
+	if _, err := f.ReadAt(hdr, 0); err != nil {
+		return imageInfo{}
+	}
+	dib := hdr[bmpFileHeaderLen:]
+	var i imageInfo
+	var bpp int
+	if binary.LittleEndian.Uint32(dib) == bmpCoreHeaderLen {
+		i.width = int(binary.LittleEndian.Uint16(dib[4:]))
+		i.height = int(binary.LittleEndian.Uint16(dib[6:]))
+		bpp = int(binary.LittleEndian.Uint16(dib[10:]))
+	} else {
+		i.width = int(int32(binary.LittleEndian.Uint32(dib[4:])))
+		i.height = int(int32(binary.LittleEndian.Uint32(dib[8:])))
+		bpp = int(binary.LittleEndian.Uint16(dib[14:]))
+	}
+	i.height = max(i.height, -i.height) // Negative heights are top-down
+	i.frames = 1
+	switch {
+	case bpp <= 8:
+		i.model = fmt.Sprintf("Indexed %d-bit", bpp)
+	case bpp == 32:
+		i.model = "RGBA 8-bit"
+	default:
+		i.model = fmt.Sprintf("RGB %d-bit", bpp)
+	}
+	return i
+}
+
+// parseWebP walks the RIFF chunks of a WebP file for the bitstream or
+// extended header, counting animation frames.
+func parseWebP(f *os.File) imageInfo {
+	var i imageInfo
+	var animated bool
+	hdr := make([]byte, 8+10)
+	for off := int64(12); ; {
+		n, _ := f.ReadAt(hdr, off)
+		if n < 8 {
+			break
+		}
+		id, size := string(hdr[:4]), int64(binary.LittleEndian.Uint32(hdr[4:]))
+		data := hdr[8:n]
+		switch id {
+		case "VP8X":
+			if len(data) >= 10 {
+				animated = data[0]&webpAnimation != 0
+				i.width = int(uint24LE(data[4:])) + 1
+				i.height = int(uint24LE(data[7:])) + 1
+				i.model = "YUV"
+				if data[0]&webpAlpha != 0 {
+					i.model = "YUVA"
+				}
+			}
+		case "VP8 ":
+			if len(data) >= 10 && i.width == 0 {
+				i.width = int(binary.LittleEndian.Uint16(data[6:]) & 0x3fff)
+				i.height = int(binary.LittleEndian.Uint16(data[8:]) & 0x3fff)
+				i.model = "YUV"
+			}
+		case "VP8L":
+			if len(data) >= 5 && i.width == 0 {
+				bits := binary.LittleEndian.Uint32(data[1:])
+				i.width = int(bits&0x3fff) + 1
+				i.height = int(bits>>14&0x3fff) + 1
+				i.model = "RGB"
+				if bits>>28&1 != 0 {
+					i.model = "RGBA"
+				}
+			}
+		case "ANMF":
+			i.frames++
+		}
+		off += 8 + size + size%2
+	}
+	if !animated || i.frames == 0 {
+		i.frames = 1
+	}
+	return i
+}
+
+func uint24LE(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// gifFrames counts image descriptors, skipping color tables and the data
+// sub-blocks of images and extensions without decoding them.
+func gifFrames(f *os.File) int {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0
+	}
+	r := bufio.NewReader(f)
+	hdr := make([]byte, gifHeaderLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return 0
+	}
+	if hdr[10]&gifTableFlag != 0 {
+		if _, err := r.Discard(colorTableLen(hdr[10])); err != nil {
+			return 0
+		}
+	}
+	frames := 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil || b == gifTrailer {
+			return frames
+		}
+		switch b {
+		case gifExtension:
+			if _, err := r.ReadByte(); err != nil { // Label
+				return frames
+			}
+		case gifImage:
+			desc := make([]byte, gifImageDescr)
+			if _, err := io.ReadFull(r, desc); err != nil {
+				return frames
+			}
+			if desc[8]&gifTableFlag != 0 {
+				if _, err := r.Discard(colorTableLen(desc[8])); err != nil {
+					return frames
+				}
+			}
+			if _, err := r.ReadByte(); err != nil { // LZW minimum code size
+				return frames
+			}
+			frames++
+		default:
+			return frames
+		}
+		if !skipSubBlocks(r) {
+			return frames
+		}
+	}
+}
+
+func colorTableLen(flags byte) int {
+	return 3 << (flags&0x07 + 1)
+}
+
+func skipSubBlocks(r *bufio.Reader) bool {
+	for {
+		n, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		if n == 0 {
+			return true
+		}
+		if _, err := r.Discard(int(n)); err != nil {
+			return false
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package entry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	isoSectorSize   = 2048
+	isoFirstVolDesc = 16
+	isoMaxVolDescs  = 64
+	isoMaxDirSize   = 16 << 20 // Larger directories are skipped as corrupt
+
+	isoVolPrimary       = 1
+	isoVolSupplementary = 2
+	isoVolTerminator    = 255
+
+	isoRootRecordOffset = 156
+	isoEscapeOffset     = 88
+	isoFlagDir          = 0x02
+	isoFlagMultiExtent  = 0x80
+
+	// Rock Ridge SL component flags
+	slContinue = 0x01
+	slCurrent  = 0x02
+	slParent   = 0x04
+	slRoot     = 0x08
+
+	// Rock Ridge NM flags
+	nmContinue = 0x01
+
+	// Rock Ridge TF flags
+	tfCreation = 0x01
+	tfModify   = 0x02
+	tfLongForm = 0x80
+)
+
+var isoMagic = []byte("CD001")
+
+// isoImage reads directory records from an ISO 9660 image.
+type isoImage struct {
+	r        io.ReaderAt
+	size     int64
+	joliet   bool
+	rock     bool
+	suspSkip int
+	visited  map[uint32]bool
+}
+
+// isoRecord is a parsed directory record, with Rock Ridge fields applied.
+type isoRecord struct {
+	name     string
+	extent   uint32
+	size     int64
+	flags    byte
+	mode     os.FileMode
+	hasMode  bool
+	uid, gid string
+	modTime  time.Time
+	target   string
+	relocate uint32 // Rock Ridge CL: directory moved to this extent
+	moved    bool   // Rock Ridge RE: relocated directory, listed via its CL
+}
+
+// readISO adds the files of an ISO 9660 image to m. Rock Ridge names,
+// modes and symlinks are used when present, then Joliet long names.
+func readISO(m *memFS, r io.ReaderAt, size int64) error {
+	var primary, joliet []byte
+	for i := range isoMaxVolDescs {
+		vd := make([]byte, isoSectorSize)
+		if _, err := r.ReadAt(vd, int64(isoFirstVolDesc+i)*isoSectorSize); err != nil {
+			return err
+		}
+		if !bytes.Equal(vd[1:6], isoMagic) {
+			return errors.New("not an ISO 9660 image")
+		}
+		switch vd[0] {
+		case isoVolPrimary:
+			if primary == nil {
+				primary = vd
+			}
+		case isoVolSupplementary:
+			if esc := vd[isoEscapeOffset : isoEscapeOffset+3]; esc[0] == '%' && esc[1] == '/' &&
+				(esc[2] == '@' || esc[2] == 'C' || esc[2] == 'E') {
+				joliet = vd
+			}
+		}
+		if vd[0] == isoVolTerminator {
+			break
+		}
+	}
+	if primary == nil {
+		return errors.New("no primary volume descriptor")
+	}
+
+	img := &isoImage{r: r, size: size, visited: make(map[uint32]bool)}
+	root, ok := parseISORecord(primary[isoRootRecordOffset:], img)
+	if !ok {
+		return errors.New("bad root directory record")
+	}
+	img.detectRockRidge(root)
+	if !img.rock && joliet != nil {
+		img.joliet = true
+		if root, ok = parseISORecord(joliet[isoRootRecordOffset:], img); !ok {
+			return errors.New("bad Joliet root directory record")
+		}
+	}
+	return img.walk(m, "", root.extent, root.size)
+}
+
+// detectRockRidge looks for the SUSP "SP" entry that opens the system use
+// area of the root directory's "." record.
+func (img *isoImage) detectRockRidge(root isoRecord) {
+	buf := make([]byte, isoSectorSize)
+	if _, err := img.r.ReadAt(buf, int64(root.extent)*isoSectorSize); err != nil {
+		return
+	}
+	rec := buf[:buf[0]]
+	if len(rec) < 34 {
+		return
+	}
+	su := rec[systemUseOffset(rec):]
+	if len(su) >= 7 && su[0] == 'S' && su[1] == 'P' && su[4] == 0xbe && su[5] == 0xef {
+		img.rock = true
+		img.suspSkip = int(su[6])
+	}
+}
+
+// walk adds the records of the directory at extent to m under dir.
+func (img *isoImage) walk(m *memFS, dir string, extent uint32, size int64) error {
+	if img.visited[extent] {
+		return nil
+	}
+	img.visited[extent] = true
+	start := int64(extent) * isoSectorSize
+	if size < 0 || size > isoMaxDirSize || start >= img.size || size > img.size-start {
+		return nil // A corrupt record; list the rest of the image
+	}
+	data := make([]byte, size)
+	if _, err := img.r.ReadAt(data, start); err != nil && err != io.EOF {
+		return err
+	}
+	var last *memNode
+	lastMulti := false
+	for off := 0; off < len(data); {
+		n := int(data[off])
+		if n == 0 {
+			// Records do not cross sectors; skip to the next one.
+			off = (off/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+		if off+n > len(data) {
+			break
+		}
+		rec, ok := parseISORecord(data[off:off+n], img)
+		off += n
+		if !ok || rec.name == "." || rec.name == ".." || rec.moved {
+			continue
+		}
+		if lastMulti && last != nil && last.name == rec.name {
+			last.size += rec.size // Further extent of a large file
+			lastMulti = rec.flags&isoFlagMultiExtent != 0
+			continue
+		}
+		node := rec.node()
+		p := path.Join(dir, rec.name)
+		m.add(p, node)
+		last, lastMulti = node, rec.flags&isoFlagMultiExtent != 0
+		if node.IsDir() {
+			childExtent, childSize := rec.extent, rec.size
+			if rec.relocate != 0 {
+				childExtent, childSize = rec.relocate, img.dirSize(rec.relocate)
+			}
+			if err := img.walk(m, p, childExtent, childSize); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dirSize reads the size of a directory from its own "." record.
+func (img *isoImage) dirSize(extent uint32) int64 {
+	buf := make([]byte, 34)
+	if _, err := img.r.ReadAt(buf, int64(extent)*isoSectorSize); err != nil {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint32(buf[10:14]))
+}
+
+func (rec isoRecord) node() *memNode {
+	n := &memNode{
+		size:    rec.size,
+		modTime: rec.modTime,
+		user:    rec.uid,
+		group:   rec.gid,
+		target:  rec.target,
+	}
+	switch {
+	case rec.hasMode:
+		n.mode = rec.mode
+	case rec.flags&isoFlagDir != 0:
+		n.mode = os.ModeDir | 0o555
+	default:
+		n.mode = 0o444
+	}
+	if rec.relocate != 0 {
+		n.mode = os.ModeDir | n.mode.Perm()
+	}
+	if n.mode&os.ModeSymlink != 0 {
+		n.size = int64(len(rec.target))
+	}
+	return n
+}
+
+// parseISORecord decodes a directory record and its system use area.
+func parseISORecord(rec []byte, img *isoImage) (isoRecord, bool) {
+	if len(rec) < 34 || int(rec[32])+33 > len(rec) {
+		return isoRecord{}, false
+	}
+	r := isoRecord{
+		extent:  binary.LittleEndian.Uint32(rec[2:6]),
+		size:    int64(binary.LittleEndian.Uint32(rec[10:14])),
+		flags:   rec[25],
+		modTime: isoRecordTime(rec[18:25]),
+	}
+	rawName := rec[33 : 33+int(rec[32])]
+	switch {
+	case len(rawName) == 1 && rawName[0] == 0:
+		r.name = "."
+	case len(rawName) == 1 && rawName[0] == 1:
+		r.name = ".."
+	case img.joliet:
+		r.name = isoFileName(decodeUCS2(rawName))
+	default:
+		r.name = isoFileName(string(rawName))
+	}
+	if img.rock {
+		su := rec[systemUseOffset(rec):]
+		if len(su) > img.suspSkip {
+			img.applySUSP(&r, su[img.suspSkip:])
+		}
+	}
+	return r, true
+}
+
+// systemUseOffset returns where the system use area of a record starts:
+// after the name, padded to an even offset.
+func systemUseOffset(rec []byte) int {
+	off := 33 + int(rec[32])
+	if off%2 == 1 {
+		off++
+	}
+	return min(off, len(rec))
+}
+
+// suspText gathers the NM name and SL target pieces of a system use area
+// and its continuation areas, which may split them.
+type suspText struct {
+	name    strings.Builder
+	hasName bool
+	target  []string
+}
+
+// applySUSP applies the Rock Ridge entries of a system use area to r,
+// following continuation areas.
+func (img *isoImage) applySUSP(r *isoRecord, su []byte) {
+	var text suspText
+	img.readSUSP(r, su, &text, 0)
+	if text.hasName {
+		r.name = text.name.String()
+	}
+	if text.target != nil {
+		r.target = strings.Join(text.target, "/")
+		if strings.HasPrefix(r.target, "//") {
+			r.target = r.target[1:]
+		}
+	}
+}
+
+// readSUSP reads the entries of one system use area into r and text.
+func (img *isoImage) readSUSP(r *isoRecord, su []byte, text *suspText, depth int) {
+	for len(su) >= 4 {
+		sig, n := string(su[:2]), int(su[2])
+		if n < 4 || n > len(su) {
+			break
+		}
+		data := su[4:n]
+		switch sig {
+		case "PX":
+			if len(data) >= 32 {
+				r.mode = unixFileMode(binary.LittleEndian.Uint32(data[0:4]))
+				r.hasMode = true
+				r.uid = strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data[16:20])), 10)
+				r.gid = strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data[24:28])), 10)
+			}
+		case "NM":
+			if len(data) >= 1 && data[0]&^nmContinue == 0 {
+				text.name.Write(data[1:])
+				text.hasName = true
+			}
+		case "SL":
+			if len(data) >= 1 {
+				text.target = appendSLComponents(text.target, data[1:])
+			}
+		case "TF":
+			if t, ok := rockRidgeModTime(data); ok {
+				r.modTime = t
+			}
+		case "CL":
+			if len(data) >= 4 {
+				r.relocate = binary.LittleEndian.Uint32(data[0:4])
+			}
+		case "RE":
+			r.moved = true
+		case "CE":
+			if len(data) >= 24 && depth < maxLinkDepth {
+				block := binary.LittleEndian.Uint32(data[0:4])
+				offset := int64(binary.LittleEndian.Uint32(data[8:12]))
+				length := int64(binary.LittleEndian.Uint32(data[16:20]))
+				start := int64(block)*isoSectorSize + offset
+				// A continuation area lies within one sector of the image.
+				if offset >= isoSectorSize || length > isoSectorSize-offset || start+length > img.size {
+					break
+				}
+				cont := make([]byte, length)
+				if _, err := img.r.ReadAt(cont, start); err == nil {
+					img.readSUSP(r, cont, text, depth+1)
+				}
+			}
+		case "ST":
+			return
+		}
+		su = su[n:]
+	}
+}
+
+// appendSLComponents appends the path components of one SL entry.
+// A component continued from the previous entry is joined to the last one.
+func appendSLComponents(parts []string, data []byte) []string {
+	continued := len(parts) > 0 && strings.HasSuffix(parts[len(parts)-1], "\x00")
+	if continued {
+		parts[len(parts)-1] = strings.TrimSuffix(parts[len(parts)-1], "\x00")
+	}
+	for len(data) >= 2 {
+		flags, n := data[0], int(data[1])
+		if 2+n > len(data) {
+			break
+		}
+		var comp string
+		switch {
+		case flags&slCurrent != 0:
+			comp = "."
+		case flags&slParent != 0:
+			comp = ".."
+		case flags&slRoot != 0:
+			comp = ""
+		default:
+			comp = string(data[2 : 2+n])
+		}
+		if continued {
+			parts[len(parts)-1] += comp
+			continued = false
+		} else {
+			parts = append(parts, comp)
+		}
+		if flags&slContinue != 0 {
+			parts[len(parts)-1] += "\x00"
+			continued = true
+		}
+		data = data[2+n:]
+	}
+	return parts
+}
+
+// rockRidgeModTime returns the modification time from a TF entry.
+func rockRidgeModTime(data []byte) (time.Time, bool) {
+	if len(data) < 1 || data[0]&tfModify == 0 {
+		return time.Time{}, false
+	}
+	size := 7
+	if data[0]&tfLongForm != 0 {
+		size = 17
+	}
+	off := 1
+	if data[0]&tfCreation != 0 {
+		off += size
+	}
+	if off+size > len(data) {
+		return time.Time{}, false
+	}
+	if size == 7 {
+		return isoRecordTime(data[off : off+7]), true
+	}
+	return isoLongTime(data[off : off+17])
+}
+
+// isoRecordTime decodes the 7-byte date and time of a directory record.
+func isoRecordTime(b []byte) time.Time {
+	if len(b) < 7 || b[1] == 0 {
+		return time.Time{}
+	}
+	zone := time.FixedZone("", int(int8(b[6]))*15*60)
+	return time.Date(1900+int(b[0]), time.Month(b[1]), int(b[2]),
+		int(b[3]), int(b[4]), int(b[5]), 0, zone)
+}
+
+// isoLongTime decodes the 17-byte "YYYYMMDDHHMMSScc" date and zone offset.
+func isoLongTime(b []byte) (time.Time, bool) {
+	digits := string(b[:16])
+	var v [7]int
+	widths := []int{4, 2, 2, 2, 2, 2, 2}
+	pos := 0
+	for i, w := range widths {
+		n, err := strconv.Atoi(digits[pos : pos+w])
+		if err != nil {
+			return time.Time{}, false
+		}
+		v[i] = n
+		pos += w
+	}
+	if v[0] == 0 {
+		return time.Time{}, false
+	}
+	zone := time.FixedZone("", int(int8(b[16]))*15*60)
+	return time.Date(v[0], time.Month(v[1]), v[2], v[3], v[4], v[5], v[6]*1e7, zone), true
+}
+
+// isoFileName strips the version suffix and the empty extension dot from
+// an ISO 9660 file identifier.
+func isoFileName(name string) string {
+	if i := strings.LastIndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+func decodeUCS2(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// unixFileMode converts a POSIX st_mode value to an os.FileMode.
+func unixFileMode(m uint32) os.FileMode {
+	mode := os.FileMode(m & 0o777)
+	switch m & 0o170000 {
+	case 0o040000:
+		mode |= os.ModeDir
+	case 0o120000:
+		mode |= os.ModeSymlink
+	case 0o020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0o060000:
+		mode |= os.ModeDevice
+	case 0o010000:
+		mode |= os.ModeNamedPipe
+	case 0o140000:
+		mode |= os.ModeSocket
+	}
+	if m&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
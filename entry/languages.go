@@ -0,0 +1,159 @@
+package entry
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commentSyntax describes how a language marks comments. Block comments
+// are not treated as nesting.
+type commentSyntax struct {
+	line       []string
+	blockStart string
+	blockEnd   string
+}
+
+var (
+	cStyle     = commentSyntax{line: []string{"//"}, blockStart: "/*", blockEnd: "*/"}
+	hashStyle  = commentSyntax{line: []string{"#"}}
+	semiStyle  = commentSyntax{line: []string{";"}}
+	xmlStyle   = commentSyntax{blockStart: "<!--", blockEnd: "-->"}
+	noComments = commentSyntax{}
+)
+
+// language is a source language and the names that identify its files.
+type language struct {
+	name         string
+	extensions   []string // Lower case, with the dot
+	filenames    []string // Exact base names
+	interpreters []string // Shebang interpreters, without version suffixes
+	comments     commentSyntax
+}
+
+var languages = []language{
+	{name: "Go", extensions: []string{".go"}, comments: cStyle},
+	{name: "C", extensions: []string{".c", ".h"}, comments: cStyle},
+	{name: "C++", extensions: []string{".cc", ".cpp", ".cxx", ".c++", ".hh", ".hpp", ".hxx"}, comments: cStyle},
+	{name: "C#", extensions: []string{".cs"}, comments: cStyle},
+	{name: "Objective-C", extensions: []string{".m", ".mm"}, comments: cStyle},
+	{name: "Java", extensions: []string{".java"}, comments: cStyle},
+	{name: "Kotlin", extensions: []string{".kt", ".kts"}, comments: cStyle},
+	{name: "Scala", extensions: []string{".scala", ".sc"}, comments: cStyle},
+	{name: "Groovy", extensions: []string{".groovy", ".gradle"}, filenames: []string{"Jenkinsfile"}, comments: cStyle},
+	{name: "Swift", extensions: []string{".swift"}, comments: cStyle},
+	{name: "Rust", extensions: []string{".rs"}, comments: cStyle},
+	{name: "Zig", extensions: []string{".zig"}, comments: commentSyntax{line: []string{"//"}}},
+	{name: "Dart", extensions: []string{".dart"}, comments: cStyle},
+	{name: "JavaScript", extensions: []string{".js", ".mjs", ".cjs", ".jsx"}, interpreters: []string{"node", "deno"}, comments: cStyle},
+	{name: "TypeScript", extensions: []string{".ts", ".mts", ".cts", ".tsx"}, comments: cStyle},
+	{name: "PHP", extensions: []string{".php"}, interpreters: []string{"php"}, comments: commentSyntax{line: []string{"//", "#"}, blockStart: "/*", blockEnd: "*/"}},
+	{name: "CSS", extensions: []string{".css"}, comments: commentSyntax{blockStart: "/*", blockEnd: "*/"}},
+	{name: "SCSS", extensions: []string{".scss", ".less"}, comments: cStyle},
+	{name: "Protocol Buffers", extensions: []string{".proto"}, comments: cStyle},
+	{name: "Python", extensions: []string{".py", ".pyw", ".pyi"}, interpreters: []string{"python"}, comments: hashStyle},
+	{name: "Ruby", extensions: []string{".rb", ".rake", ".gemspec"}, filenames: []string{"Rakefile", "Gemfile", "Vagrantfile"}, interpreters: []string{"ruby"}, comments: commentSyntax{line: []string{"#"}, blockStart: "=begin", blockEnd: "=end"}},
+	{name: "Perl", extensions: []string{".pl", ".pm", ".t"}, interpreters: []string{"perl"}, comments: hashStyle},
+	{name: "Shell", extensions: []string{".sh", ".bash", ".zsh", ".ksh"}, filenames: []string{".bashrc", ".zshrc", ".profile", ".bash_profile"}, interpreters: []string{"sh", "bash", "zsh", "ksh", "dash", "ash"}, comments: hashStyle},
+	{name: "Fish", extensions: []string{".fish"}, interpreters: []string{"fish"}, comments: hashStyle},
+	{name: "PowerShell", extensions: []string{".ps1", ".psm1", ".psd1"}, interpreters: []string{"pwsh"}, comments: commentSyntax{line: []string{"#"}, blockStart: "<#", blockEnd: "#>"}},
+	{name: "Lua", extensions: []string{".lua"}, interpreters: []string{"lua", "luajit"}, comments: commentSyntax{line: []string{"--"}, blockStart: "--[[", blockEnd: "]]"}},
+	{name: "R", extensions: []string{".r"}, interpreters: []string{"Rscript"}, comments: hashStyle},
+	{name: "Julia", extensions: []string{".jl"}, interpreters: []string{"julia"}, comments: commentSyntax{line: []string{"#"}, blockStart: "#=", blockEnd: "=#"}},
+	{name: "Elixir", extensions: []string{".ex", ".exs"}, interpreters: []string{"elixir"}, comments: hashStyle},
+	{name: "Erlang", extensions: []string{".erl", ".hrl"}, interpreters: []string{"escript"}, comments: commentSyntax{line: []string{"%"}}},
+	{name: "Haskell", extensions: []string{".hs", ".lhs"}, interpreters: []string{"runhaskell"}, comments: commentSyntax{line: []string{"--"}, blockStart: "{-", blockEnd: "-}"}},
+	{name: "OCaml", extensions: []string{".ml", ".mli"}, interpreters: []string{"ocaml"}, comments: commentSyntax{blockStart: "(*", blockEnd: "*)"}},
+	{name: "F#", extensions: []string{".fs", ".fsi", ".fsx"}, comments: commentSyntax{line: []string{"//"}, blockStart: "(*", blockEnd: "*)"}},
+	{name: "Clojure", extensions: []string{".clj", ".cljs", ".cljc", ".edn"}, comments: semiStyle},
+	{name: "Lisp", extensions: []string{".lisp", ".lsp", ".el", ".scm", ".rkt"}, comments: semiStyle},
+	{name: "Nim", extensions: []string{".nim"}, comments: commentSyntax{line: []string{"#"}, blockStart: "#[", blockEnd: "]#"}},
+	{name: "Tcl", extensions: []string{".tcl"}, interpreters: []string{"tclsh", "wish"}, comments: hashStyle},
+	{name: "AWK", extensions: []string{".awk"}, interpreters: []string{"awk", "gawk", "mawk"}, comments: hashStyle},
+	{name: "SQL", extensions: []string{".sql"}, comments: commentSyntax{line: []string{"--"}, blockStart: "/*", blockEnd: "*/"}},
+	{name: "Assembly", extensions: []string{".s", ".asm"}, comments: commentSyntax{line: []string{";", "#", "//"}, blockStart: "/*", blockEnd: "*/"}},
+	{name: "Vim script", extensions: []string{".vim"}, filenames: []string{".vimrc"}, comments: commentSyntax{line: []string{"\""}}},
+	{name: "Makefile", extensions: []string{".mk", ".mak"}, filenames: []string{"Makefile", "makefile", "GNUmakefile"}, interpreters: []string{"make"}, comments: hashStyle},
+	{name: "CMake", extensions: []string{".cmake"}, filenames: []string{"CMakeLists.txt"}, comments: hashStyle},
+	{name: "Dockerfile", extensions: []string{".dockerfile"}, filenames: []string{"Dockerfile", "Containerfile"}, comments: hashStyle},
+	{name: "Starlark", extensions: []string{".bzl", ".star"}, filenames: []string{"BUILD", "BUILD.bazel", "WORKSPACE", "MODULE.bazel", "Tiltfile"}, comments: hashStyle},
+	{name: "Nix", extensions: []string{".nix"}, comments: commentSyntax{line: []string{"#"}, blockStart: "/*", blockEnd: "*/"}},
+	{name: "Terraform", extensions: []string{".tf", ".tfvars", ".hcl"}, comments: commentSyntax{line: []string{"#", "//"}, blockStart: "/*", blockEnd: "*/"}},
+	{name: "YAML", extensions: []string{".yml", ".yaml"}, comments: hashStyle},
+	{name: "TOML", extensions: []string{".toml"}, filenames: []string{"Pipfile"}, comments: hashStyle},
+	{name: "INI", extensions: []string{".ini", ".cfg", ".conf"}, comments: commentSyntax{line: []string{";", "#"}}},
+	{name: "JSON", extensions: []string{".json"}, comments: noComments},
+	{name: "XML", extensions: []string{".xml", ".xsd", ".xsl", ".plist", ".csproj"}, comments: xmlStyle},
+	{name: "HTML", extensions: []string{".html", ".htm", ".xhtml"}, comments: xmlStyle},
+	{name: "Vue", extensions: []string{".vue", ".svelte"}, comments: xmlStyle},
+	{name: "Markdown", extensions: []string{".md", ".markdown"}, comments: xmlStyle},
+	{name: "reStructuredText", extensions: []string{".rst"}, comments: noComments},
+	{name: "TeX", extensions: []string{".tex", ".sty", ".cls"}, comments: commentSyntax{line: []string{"%"}}},
+}
+
+// Lookup tables built from languages.
+var (
+	langByExt         = make(map[string]*language)
+	langByFilename    = make(map[string]*language)
+	langByInterpreter = make(map[string]*language)
+)
+
+func init() {
+	for i := range languages {
+		l := &languages[i]
+		for _, ext := range l.extensions {
+			langByExt[ext] = l
+		}
+		for _, name := range l.filenames {
+			langByFilename[name] = l
+		}
+		for _, interp := range l.interpreters {
+			langByInterpreter[interp] = l
+		}
+	}
+}
+
+// detectLanguage identifies a file's language by its exact name, then
+// its extension, then the interpreter named on a shebang line.
+func detectLanguage(name string, f *os.File) *language {
+	if l, ok := langByFilename[name]; ok {
+		return l
+	}
+	if strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile") {
+		return langByFilename["Dockerfile"]
+	}
+	if l, ok := langByExt[strings.ToLower(filepath.Ext(name))]; ok {
+		return l
+	}
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if _, seekErr := f.Seek(0, io.SeekStart); seekErr != nil || err != nil && line == "" {
+		return nil
+	}
+	return langByInterpreter[shebangInterpreter(line)]
+}
+
+// shebangInterpreter returns the interpreter of a "#!" line, looking past
+// env and its options, with version suffixes such as "3.12" removed.
+func shebangInterpreter(line string) string {
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = filepath.Base(f)
+				break
+			}
+		}
+	}
+	return strings.TrimRight(interp, "0123456789.")
+}
@@ -2,6 +2,7 @@ package entry
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -46,20 +47,73 @@ type row struct {
 	group   string
 	size    string
 	modTime string
+	extra   []string
 	name    string
 	target  string
 }
 
 type columnWidths struct {
 	perms, user, group, size, mod int
+	extra                         []int
+}
+
+// column is an optional long-format column shown between the
+// modification time and the name. Optional columns are left out of a
+// listing in which no entry has a value for them.
+type column struct {
+	header     string
+	rightAlign bool
+	optional   bool
+	value      func(Entry) string
+}
+
+// extraColumns returns the optional columns enabled by flags, in display order.
+func extraColumns() []column {
+	var cols []column
+	if cfg.Media {
+		cols = append(cols, mediaColumns()...)
+	}
+	if cfg.Images {
+		cols = append(cols, imageColumns()...)
+	}
+	if cfg.Exif {
+		cols = append(cols, exifColumns()...)
+	}
+	if cfg.Docs {
+		cols = append(cols, docColumns()...)
+	}
+	if cfg.Binary {
+		cols = append(cols, binaryColumns()...)
+	}
+	if cfg.Text {
+		cols = append(cols, textColumns()...)
+	}
+	if cfg.Code {
+		cols = append(cols, codeColumns()...)
+	}
+	if cfg.Mime {
+		cols = append(cols, mimeColumn())
+	}
+	if cfg.LastCommit {
+		cols = append(cols, commitColumns()...)
+	}
+	if cfg.Certs {
+		cols = append(cols, certColumns()...)
+	}
+	if img, ok := fsys.(*imageFS); ok {
+		cols = append(cols, layerColumn(img))
+	}
+	return cols
 }
 
 func renderLong(entries []Entry) string {
 	if len(entries) == 0 {
 		return ""
 	}
-	
-	rows, widths := buildTable(entries)
+
+	cols := extraColumns()
+	rows, widths := buildTable(entries, cols)
+	cols = dropEmptyColumns(cols, rows, &widths)
 	summary := summaryLine(entries)
 
 	var sb strings.Builder
@@ -73,13 +127,17 @@ func renderLong(entries []Entry) string {
 			group:   headerGroup,
 			size:    headerSize,
 			modTime: headerModTime,
+			extra:   make([]string, len(cols)),
 			name:    headerName,
 		}
-		writeRow(&sb, header, widths)
+		for i, c := range cols {
+			header.extra[i] = c.header
+		}
+		writeRow(&sb, header, widths, cols)
 	}
 
 	for _, r := range rows {
-		writeRow(&sb, r, widths)
+		writeRow(&sb, r, widths, cols)
 	}
 	return sb.String()
 }
@@ -97,12 +155,15 @@ func estimateCapacity(rows []row, widths columnWidths) (capacity int) {
 		capacity += len(headerPerms+headerGroup+headerUser+headerSize+headerModTime+headerName) + fieldPadding
 	}
 	baseRowWidth := widths.perms + widths.user + widths.group + widths.size + widths.mod + fieldPadding
+	for _, w := range widths.extra {
+		baseRowWidth += w + 1
+	}
 	capacity += len(rows) * baseRowWidth
 	for _, r := range rows {
 		// Add visible width of name and symlink target
 		capacity += visibleWidth(r.name) + len(r.target)
 	}
-	if !cfg.NoColor {
+	if !color.disabled() {
 		// Add capacity for colored output (permissions + metadata fields)
 		capacity += len(rows) * (len(placeholderPerms) + coloredColumns) * maxAnsiSeqLen
 	}
@@ -111,69 +172,130 @@ func estimateCapacity(rows []row, widths columnWidths) (capacity int) {
 	return capacity
 }
 
-func writeRow(sb *strings.Builder, r row, widths columnWidths) {
-	fmt.Fprintf(sb, "%s %s %s %s %s %s%s\n",
+func writeRow(sb *strings.Builder, r row, widths columnWidths, cols []column) {
+	fmt.Fprintf(sb, "%s %s %s %s %s ",
 		padToWidth(r.perms, widths.perms, false),
 		padToWidth(r.user, widths.user, false),
 		padToWidth(r.group, widths.group, false),
 		padToWidth(r.size, widths.size, true),
 		padToWidth(r.modTime, widths.mod, false),
-		r.name,
-		r.target,
 	)
+	for i, c := range cols {
+		sb.WriteString(padToWidth(r.extra[i], widths.extra[i], c.rightAlign))
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(r.name)
+	sb.WriteString(r.target)
+	sb.WriteByte('\n')
 }
 
-func buildTable(entries []Entry) ([]row, columnWidths) {
-	var widths columnWidths
+func buildTable(entries []Entry, cols []column) ([]row, columnWidths) {
+	widths := columnWidths{extra: make([]int, len(cols))}
 	if cfg.Header {
-		widths = columnWidths{
-			perms: len(headerPerms),
-			user:  len(headerUser),
-			group: len(headerGroup),
-			size:  len(headerSize),
-			mod:   len(headerModTime),
+		widths.perms = len(headerPerms)
+		widths.user = len(headerUser)
+		widths.group = len(headerGroup)
+		widths.size = len(headerSize)
+		widths.mod = len(headerModTime)
+		for i, c := range cols {
+			widths.extra[i] = visibleWidth(c.header)
 		}
 	}
 	rows := make([]row, len(entries))
+	largest := largestSize(entries)
 	for i, entry := range entries {
-		rows[i] = makeRow(entry)
+		rows[i] = makeRow(entry, cols, largest)
 		widths.perms = max(widths.perms, visibleWidth(rows[i].perms))
 		widths.user = max(widths.user, visibleWidth(rows[i].user))
 		widths.group = max(widths.group, visibleWidth(rows[i].group))
 		widths.size = max(widths.size, visibleWidth(rows[i].size))
 		widths.mod = max(widths.mod, visibleWidth(rows[i].modTime))
+		for j, v := range rows[i].extra {
+			widths.extra[j] = max(widths.extra[j], visibleWidth(v))
+		}
 	}
 	return rows, widths
 }
 
-func makeRow(entry Entry) row {
+// dropEmptyColumns removes optional columns holding only placeholders
+// from cols, rows and widths, and returns the remaining columns.
+func dropEmptyColumns(cols []column, rows []row, widths *columnWidths) []column {
+	empty := color.placeholder(placeholderField)
+	keep := make([]bool, len(cols))
+	for i, c := range cols {
+		keep[i] = !c.optional
+		for j := 0; j < len(rows) && !keep[i]; j++ {
+			keep[i] = rows[j].extra[i] != empty
+		}
+	}
+	if !slices.Contains(keep, false) {
+		return cols
+	}
+	compact := func(i int, _ string) bool { return !keep[i] }
+	for i := range rows {
+		rows[i].extra = deleteIndexed(rows[i].extra, compact)
+	}
+	widths.extra = deleteIndexed(widths.extra, func(i, _ int) bool { return !keep[i] })
+	return deleteIndexed(cols, func(i int, _ column) bool { return !keep[i] })
+}
+
+// deleteIndexed returns s without the elements for which del reports true.
+func deleteIndexed[T any](s []T, del func(int, T) bool) []T {
+	out := s[:0]
+	for i, v := range s {
+		if !del(i, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func makeRow(entry Entry, cols []column, largest int64) row {
 	if entry.link != nil && cfg.Dereference {
+		extra := make([]string, len(cols))
+		for i := range cols {
+			extra[i] = color.placeholder(placeholderField)
+		}
 		return row{
 			perms:   color.placeholder(placeholderPerms),
 			user:    color.placeholder(placeholderField),
 			group:   color.placeholder(placeholderField),
 			size:    color.placeholder(placeholderField),
 			modTime: color.placeholder(placeholderField),
+			extra:   extra,
 			name:    entry.DisplayName(),
 			target:  placeholderNonexist,
 		}
 	}
-	user, group := userGroup(entry)
+	user, group := entryOwner(entry)
 	r := row{
 		perms:   color.permissions(entry.Mode()),
-		user:    color.user(user),
-		group:   color.group(group),
-		size:    formatSize(entry.Size()),
-		modTime: color.modTime(formatModTime(entry.ModTime())),
+		user:    ownerField(user, color.user),
+		group:   ownerField(group, color.group),
+		size:    scaledSize(entry.Size(), largest),
+		modTime: color.age(entry.ModTime(), formatModTime(entry.ModTime())),
+		extra:   make([]string, len(cols)),
 		name:    entry.DisplayName(),
 	}
+	for i, c := range cols {
+		r.extra[i] = c.value(entry)
+	}
 	if entry.link != nil {
-		r.target = linkPrefix + entry.link.target
+		r.target = linkPrefix + color.linkTarget(entry)
 		r.size = formatSize(int64(len(entry.link.target)))
 	}
 	return r
 }
 
+// ownerField colors an owner or group name, or shows a placeholder
+// when the entry does not record one.
+func ownerField(name string, colorize func(string) string) string {
+	if name == "" {
+		return color.placeholder(placeholderField)
+	}
+	return colorize(name)
+}
+
 func padToWidth(s string, width int, rightAlign bool) string {
 	paddingNeeded := width - visibleWidth(s)
 	if paddingNeeded <= 0 {
@@ -187,24 +309,30 @@ func padToWidth(s string, width int, rightAlign bool) string {
 }
 
 func formatSize(size int64) string {
+	text, code := humanSize(size)
+	return color.colorize(text, code)
+}
+
+// humanSize formats a size with a unit suffix and returns the color of its band.
+func humanSize(size int64) (string, string) {
 	if size < 0 {
 		size = 0
 	}
 	switch {
 	case size < kb:
-		return color.colorize(fmt.Sprintf("%d", size), colorSizeBytes)
+		return fmt.Sprintf("%d", size), color.theme.sizeBytes
 	case size < mb:
-		return color.colorize(fmt.Sprintf("%.1fK", float64(size)/kb), colorSizeKB)
+		return fmt.Sprintf("%.1fK", float64(size)/kb), color.theme.sizeKB
 	case size < gb:
-		return color.colorize(fmt.Sprintf("%.1fM", float64(size)/mb), colorSizeMB)
+		return fmt.Sprintf("%.1fM", float64(size)/mb), color.theme.sizeMB
 	case size < tb:
-		return color.colorize(fmt.Sprintf("%.1fG", float64(size)/gb), colorSizeGB)
+		return fmt.Sprintf("%.1fG", float64(size)/gb), color.theme.sizeGB
 	case size < pb:
-		return color.colorize(fmt.Sprintf("%.1fT", float64(size)/tb), colorSizeTB)
+		return fmt.Sprintf("%.1fT", float64(size)/tb), color.theme.sizeTB
 	case size < eb:
-		return color.colorize(fmt.Sprintf("%.1fP", float64(size)/pb), colorSizePB)
+		return fmt.Sprintf("%.1fP", float64(size)/pb), color.theme.sizePB
 	default:
-		return color.colorize(fmt.Sprintf("%.1fE", float64(size)/eb), colorSizeEB)
+		return fmt.Sprintf("%.1fE", float64(size)/eb), color.theme.sizeEB
 	}
 }
 
@@ -0,0 +1,294 @@
+package entry
+
+import (
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LS_COLORS keys beyond those returned by Classify.
+const (
+	typeNormal              = "no"
+	typeFifo                = "pi"
+	typeSocket              = "so"
+	typeDoor                = "do"
+	typeBlockDevice         = "bd"
+	typeCharDevice          = "cd"
+	typeSetuid              = "su"
+	typeSetgid              = "sg"
+	typeStickyOtherWritable = "tw"
+	typeOtherWritable       = "ow"
+	typeSticky              = "st"
+	typeMissing             = "mi"
+	typeMultiHardlink       = "mh"
+	typeCapability          = "ca"
+
+	keyLeftCode  = "lc"
+	keyRightCode = "rc"
+	keyEndCode   = "ec"
+	keyReset     = "rs"
+
+	linkAsTarget = "target" // ln=target colors a symlink like the file it points to
+
+	otherWriteBit = 0o002
+)
+
+// lsColors is a compiled LS_COLORS value: codes for file types and for
+// name patterns, in definition order.
+type lsColors struct {
+	types    map[string]string
+	patterns []lsPattern
+}
+
+// lsPattern matches file names. Patterns of the form "*suffix" match by
+// suffix; any other wildcard makes the pattern a glob over the whole name.
+// Matching ignores case unless another pattern differs only in case.
+type lsPattern struct {
+	pattern       string
+	code          string
+	glob          bool
+	caseSensitive bool
+}
+
+// parseLSColors compiles a value in the format of LS_COLORS on top of the
+// built-in type colors. Later definitions take precedence, as in GNU ls.
+func parseLSColors(spec string) lsColors {
+	ls := lsColors{types: maps.Clone(fallbackColors)}
+	for _, item := range strings.Split(spec, ":") {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || key == "" {
+			continue
+		}
+		key, value = unescapeColor(key), unescapeColor(value)
+		if !strings.ContainsAny(key, "*?[") {
+			ls.types[key] = value
+			continue
+		}
+		p := lsPattern{pattern: key, code: value}
+		if rest, ok := strings.CutPrefix(key, "*"); ok && !strings.ContainsAny(rest, "*?[") {
+			p.pattern = rest
+		} else {
+			p.glob = true
+		}
+		ls.patterns = append(ls.patterns, p)
+	}
+
+	folded := make(map[string]string)
+	for i, p := range ls.patterns {
+		lower := strings.ToLower(p.pattern)
+		if other, ok := folded[lower]; ok && other != p.pattern {
+			ls.patterns[i].caseSensitive = true
+			for j := range ls.patterns[:i] {
+				if strings.ToLower(ls.patterns[j].pattern) == lower {
+					ls.patterns[j].caseSensitive = true
+				}
+			}
+		}
+		folded[lower] = p.pattern
+	}
+	return ls
+}
+
+// unescapeColor decodes the escapes dircolors allows in keys and values:
+// backslash escapes, octal and hex codes, and caret notation.
+func unescapeColor(s string) string {
+	if !strings.ContainsAny(s, `\^`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '^' && i+1 < len(s):
+			i++
+			if s[i] == '?' {
+				sb.WriteByte(0x7f)
+			} else {
+				sb.WriteByte(s[i] & 0x1f)
+			}
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'a':
+				sb.WriteByte('\a')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'e':
+				sb.WriteByte(0x1b)
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'v':
+				sb.WriteByte('\v')
+			case '?':
+				sb.WriteByte(0x7f)
+			case '_':
+				sb.WriteByte(' ')
+			case 'x', 'X':
+				j := i + 1
+				for j < len(s) && j < i+3 && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+					j++
+				}
+				n, _ := strconv.ParseUint(s[i+1:j], 16, 8)
+				sb.WriteByte(byte(n))
+				i = j - 1
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				j := i
+				for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+					j++
+				}
+				n, _ := strconv.ParseUint(s[i:j], 8, 8)
+				sb.WriteByte(byte(n))
+				i = j - 1
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// isColored reports whether key has a code that changes the output, as
+// GNU ls only applies the special permission types when they do.
+func (ls lsColors) isColored(key string) bool {
+	code := ls.types[key]
+	return code != "" && code != "0" && code != "00"
+}
+
+// match returns the code of the most recently defined pattern matching name.
+func (ls lsColors) match(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for i := len(ls.patterns) - 1; i >= 0; i-- {
+		p := ls.patterns[i]
+		subject, pattern := lower, strings.ToLower(p.pattern)
+		if p.caseSensitive {
+			subject, pattern = name, p.pattern
+		}
+		if p.glob {
+			if ok, _ := path.Match(pattern, subject); ok {
+				return p.code, true
+			}
+		} else if strings.HasSuffix(subject, pattern) {
+			return p.code, true
+		}
+	}
+	return "", false
+}
+
+// start and end return the sequences around a colored file name.
+func (ls lsColors) start(code string) string {
+	return ls.orDefault(keyLeftCode, ansiEscapePrefix) + code + ls.orDefault(keyRightCode, "m")
+}
+
+func (ls lsColors) end() string {
+	if end, ok := ls.types[keyEndCode]; ok {
+		return end
+	}
+	return ls.start(ls.orDefault(keyReset, "0"))
+}
+
+func (ls lsColors) orDefault(key, def string) string {
+	if v, ok := ls.types[key]; ok {
+		return v
+	}
+	return def
+}
+
+// colorCode returns the LS_COLORS code for an entry named fileName,
+// following GNU ls: symlinks first, then special file types and
+// permissions, and name patterns only for plain files. With ln=target a
+// symlink is colored by its target's type and name.
+func (c colorizer) colorCode(e Entry, fileName string) string {
+	if e.link != nil && !cfg.Dereference {
+		switch {
+		case e.link.isBroken && c.ls.isColored(typeBrokenLink):
+			return c.ls.types[typeBrokenLink]
+		case !e.link.isBroken && e.link.targetInfo != nil && c.ls.types[typeLink] == linkAsTarget:
+			return c.colorCode(Entry{FileInfo: e.link.targetInfo, path: linkTargetPath(e)}, filepath.Base(e.link.target))
+		}
+		return c.ls.types[typeLink]
+	}
+
+	key := c.typeKey(e)
+	if key == typeFile {
+		if code, ok := c.ls.match(fileName); ok {
+			return code
+		}
+		if cfg.Mime {
+			m := detectMIME(e)
+			if code, ok := mimeColors[m]; ok {
+				return code
+			}
+			if top, _, _ := strings.Cut(m, "/"); mimeColors[top] != "" {
+				return mimeColors[top]
+			}
+		}
+	}
+	if code, ok := c.ls.types[key]; ok && code != "" {
+		return code
+	}
+	return c.ls.types[typeNormal]
+}
+
+// typeKey classifies an entry by file type and permission bits into the
+// LS_COLORS key used when no name pattern applies.
+func (c colorizer) typeKey(e Entry) string {
+	mode := e.Mode()
+	switch {
+	case mode.IsDir():
+		sticky, otherWritable := mode&os.ModeSticky != 0, mode.Perm()&otherWriteBit != 0
+		switch {
+		case sticky && otherWritable && c.ls.isColored(typeStickyOtherWritable):
+			return typeStickyOtherWritable
+		case otherWritable && c.ls.isColored(typeOtherWritable):
+			return typeOtherWritable
+		case sticky && c.ls.isColored(typeSticky):
+			return typeSticky
+		}
+		return typeDir
+	case mode&os.ModeNamedPipe != 0:
+		return typeFifo
+	case mode&os.ModeSocket != 0:
+		return typeSocket
+	case mode&os.ModeCharDevice != 0:
+		return typeCharDevice
+	case mode&os.ModeDevice != 0:
+		return typeBlockDevice
+	case mode&os.ModeSymlink != 0:
+		return typeLink
+	case mode&os.ModeSetuid != 0 && c.ls.isColored(typeSetuid):
+		return typeSetuid
+	case mode&os.ModeSetgid != 0 && c.ls.isColored(typeSetgid):
+		return typeSetgid
+	case c.ls.isColored(typeCapability) && isOSEntry(e) && hasCapability(e.path):
+		return typeCapability
+	case mode&execBits != 0:
+		return typeExec
+	case linkCount(e) > 1 && c.ls.isColored(typeMultiHardlink):
+		return typeMultiHardlink
+	}
+	return typeFile
+}
+
+// linkTargetPath resolves a symlink's target relative to its directory.
+func linkTargetPath(e Entry) string {
+	if filepath.IsAbs(e.link.target) {
+		return e.link.target
+	}
+	return filepath.Join(filepath.Dir(e.path), e.link.target)
+}
+
+func isOSEntry(e Entry) bool {
+	_, ok := fsys.(osFS)
+	return ok && e.path != ""
+}
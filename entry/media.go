@@ -0,0 +1,180 @@
+package entry
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+const (
+	headerDuration   = "Duration"
+	headerBitrate    = "Bitrate"
+	headerSampleRate = "Rate"
+	headerChannels   = "Ch"
+	headerResolution = "Resolution"
+	headerFrameRate  = "FPS"
+	headerCodec      = "Codec"
+
+	sniffLen = 12
+)
+
+// mediaInfo holds stream properties parsed from a media file's headers.
+// Zero fields are unknown.
+type mediaInfo struct {
+	duration   time.Duration
+	bitrate    int // bits per second
+	sampleRate int // Hz
+	channels   int
+	width      int
+	height     int
+	frameRate  float64
+	codec      string // fourcc or short codec name
+}
+
+// mediaFile is an open media file with its size, for parsers that derive
+// durations from the amount of stream data.
+type mediaFile struct {
+	*os.File
+	size int64
+}
+
+var mediaCache = make(map[string]mediaInfo)
+
+func mediaColumns() []column {
+	return []column{
+		{header: headerDuration, rightAlign: true, value: func(e Entry) string {
+			m := probeMedia(e)
+			if m.duration <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.duration(formatDuration(m.duration))
+		}},
+		{header: headerBitrate, rightAlign: true, value: func(e Entry) string {
+			m := probeMedia(e)
+			if m.bitrate <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(fmt.Sprintf("%dk", (m.bitrate+500)/1000))
+		}},
+		{header: headerSampleRate, rightAlign: true, value: func(e Entry) string {
+			m := probeMedia(e)
+			if m.sampleRate <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(formatSampleRate(m.sampleRate))
+		}},
+		{header: headerChannels, rightAlign: true, value: func(e Entry) string {
+			m := probeMedia(e)
+			if m.channels <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(fmt.Sprint(m.channels))
+		}},
+		{header: headerResolution, rightAlign: true, optional: true, value: func(e Entry) string {
+			m := probeMedia(e)
+			if m.width <= 0 || m.height <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(fmt.Sprintf("%dx%d", m.width, m.height))
+		}},
+		{header: headerFrameRate, rightAlign: true, optional: true, value: func(e Entry) string {
+			m := probeMedia(e)
+			if m.frameRate <= 0 {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(formatFrameRate(m.frameRate))
+		}},
+		{header: headerCodec, value: func(e Entry) string {
+			m := probeMedia(e)
+			if m.codec == "" {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(m.codec)
+		}},
+	}
+}
+
+// probeMedia parses the headers of a media file, identified by its magic
+// number. Results are cached per path; unrecognized files yield zero info.
+func probeMedia(e Entry) mediaInfo {
+	if m, ok := mediaCache[e.path]; ok {
+		return m
+	}
+	var m mediaInfo
+	if f, err := openEntry(e); err == nil {
+		m = parseMedia(mediaFile{File: f, size: e.Size()})
+		f.Close()
+	}
+	mediaCache[e.path] = m
+	return m
+}
+
+func parseMedia(f mediaFile) mediaInfo {
+	magic := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, magic)
+	magic = magic[:n]
+	switch {
+	case hasMagic(magic, 0, "fLaC"):
+		return parseFLAC(f)
+	case hasMagic(magic, 0, "RIFF") && hasMagic(magic, 8, "WAVE"):
+		return parseWAV(f)
+	case hasMagic(magic, 0, "RIFF") && hasMagic(magic, 8, "AVI "):
+		return parseAVI(f)
+	case hasMagic(magic, 0, "OggS"):
+		return parseOgg(f)
+	case hasMagic(magic, 0, "\x1a\x45\xdf\xa3"):
+		return parseMatroska(f)
+	case isMP4(magic):
+		return parseMP4(f)
+	case hasMagic(magic, 0, "ID3") || isMPEGSync(magic):
+		return parseMP3(f)
+	}
+	return mediaInfo{}
+}
+
+func hasMagic(b []byte, offset int, magic string) bool {
+	return len(b) >= offset+len(magic) && string(b[offset:offset+len(magic)]) == magic
+}
+
+// durationOf converts a sample count at a rate to a duration.
+func durationOf(samples int64, rate int) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(rate) * float64(time.Second))
+}
+
+// averageBitrate returns the bitrate of size bytes played over d.
+func averageBitrate(size int64, d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(float64(size*8) / d.Seconds())
+}
+
+// formatDuration formats d as m:ss, or h:mm:ss from an hour up.
+func formatDuration(d time.Duration) string {
+	s := int64(d.Round(time.Second) / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// formatFrameRate shows whole rates as integers and others, such as
+// 29.97, with two decimals.
+func formatFrameRate(fps float64) string {
+	if math.Abs(fps-math.Round(fps)) < 0.005 {
+		return fmt.Sprintf("%.0f", fps)
+	}
+	return fmt.Sprintf("%.2f", fps)
+}
+
+func formatSampleRate(hz int) string {
+	if hz%1000 == 0 {
+		return fmt.Sprintf("%dk", hz/1000)
+	}
+	return fmt.Sprintf("%.1fk", float64(hz)/1000)
+}
@@ -0,0 +1,221 @@
+package entry
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	headerMIME = "MIME"
+
+	mimeDirectory = "inode/directory"
+	mimeSymlink   = "inode/symlink"
+	mimeEmpty     = "inode/x-empty"
+	mimeSpecial   = "inode/x-special"
+	mimeTextPlain = "text/plain"
+	mimeZip       = "application/zip"
+
+	mimeSniffLen = 512 // What http.DetectContentType considers
+	isoMagicAt   = 0x8001
+	tarMagicAt   = 257
+)
+
+// mimeMagic maps a signature at an offset to a type. Entries are tried in
+// order, before http.DetectContentType, for formats it does not know.
+var mimeMagic = []struct {
+	offset int
+	magic  string
+	mime   string
+}{
+	{0, "\x7fELF", "application/x-executable"},
+	{0, "MZ", "application/vnd.microsoft.portable-executable"},
+	{0, "\xfe\xed\xfa\xce", "application/x-mach-binary"},
+	{0, "\xfe\xed\xfa\xcf", "application/x-mach-binary"},
+	{0, "\xce\xfa\xed\xfe", "application/x-mach-binary"},
+	{0, "\xcf\xfa\xed\xfe", "application/x-mach-binary"},
+	{0, "\x00asm", "application/wasm"},
+	{0, "SQLite format 3\x00", "application/vnd.sqlite3"},
+	{0, "%PDF-", "application/pdf"},
+	{0, "\x1f\x8b", "application/gzip"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{0, "\x28\xb5\x2f\xfd", "application/zstd"},
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{0, "Rar!\x1a\x07", "application/vnd.rar"},
+	{tarMagicAt, "ustar", "application/x-tar"},
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "8BPS", "image/vnd.adobe.photoshop"},
+	{0, "\x00\x00\x01\x00", "image/vnd.microsoft.icon"},
+	{4, "ftypheic", "image/heic"},
+	{4, "ftypavif", "image/avif"},
+	{4, "ftypqt  ", "video/quicktime"},
+	{4, "ftypM4A ", "audio/mp4"},
+	{4, "ftyp", "video/mp4"},
+	{0, "fLaC", "audio/flac"},
+	{0, "ID3", "audio/mpeg"},
+	{0, "MThd", "audio/midi"},
+	{0, "wOFF", "font/woff"},
+	{0, "wOF2", "font/woff2"},
+	{0, "OTTO", "font/otf"},
+	{0, "\x00\x01\x00\x00\x00", "font/ttf"},
+	{0, "-----BEGIN ", "application/x-pem-file"},
+}
+
+// shebangTypes maps interpreters to script types.
+var shebangTypes = map[string]string{
+	"sh":      "text/x-shellscript",
+	"bash":    "text/x-shellscript",
+	"zsh":     "text/x-shellscript",
+	"dash":    "text/x-shellscript",
+	"ksh":     "text/x-shellscript",
+	"python":  "text/x-python",
+	"perl":    "text/x-perl",
+	"ruby":    "text/x-ruby",
+	"node":    "text/javascript",
+	"php":     "application/x-php",
+	"lua":     "text/x-lua",
+	"awk":     "text/x-awk",
+	"Rscript": "text/x-r",
+}
+
+// ooxmlTypes maps the top-level folder of an Office Open XML package to its type.
+var ooxmlTypes = map[string]string{
+	"word/": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"xl/":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"ppt/":  "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// textualHints mark types from the extension table that describe text, so
+// they may refine content sniffed as plain text.
+var textualHints = []string{"text/", "json", "xml", "javascript", "script", "x-sh", "yaml", "toml", "sql", "x-tex", "x-php"}
+
+var mimeCache = make(map[string]string)
+
+func mimeColumn() column {
+	return column{header: headerMIME, value: func(e Entry) string {
+		return textField(detectMIME(e), color.media)
+	}}
+}
+
+// detectMIME identifies an entry's media type from its content, falling
+// back to the system's extension table only to refine plain text. The
+// result is "" for virtual entries, whose content cannot be read.
+func detectMIME(e Entry) string {
+	switch {
+	case e.link != nil && !cfg.Dereference:
+		return mimeSymlink
+	case e.IsDir():
+		return mimeDirectory
+	case !e.Mode().IsRegular():
+		return mimeSpecial
+	case e.Size() == 0:
+		return mimeEmpty
+	}
+	if m, ok := mimeCache[e.path]; ok {
+		return m
+	}
+	var m string
+	if f, err := openEntry(e); err == nil {
+		m = sniffMIME(f, e.Size())
+		f.Close()
+		if m == mimeTextPlain {
+			m = refineText(e.Name())
+		}
+	}
+	mimeCache[e.path] = m
+	return m
+}
+
+func sniffMIME(f *os.File, size int64) string {
+	head := make([]byte, mimeSniffLen)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+
+	switch {
+	case hasMagic(head, 0, "PK\x03\x04"):
+		return zipMIME(f, size)
+	case hasMagic(head, 0, "\xca\xfe\xba\xbe") && len(head) >= 8:
+		// Shared by Java classes and universal Mach-O binaries, which
+		// count architectures where classes store their version.
+		if binary.BigEndian.Uint32(head[4:]) < 0x20 {
+			return "application/x-mach-binary"
+		}
+		return "application/java-vm"
+	case hasMagic(head, 0, "RIFF") && hasMagic(head, 8, "WAVE"):
+		return "audio/wav"
+	case hasMagic(head, 0, "RIFF") && hasMagic(head, 8, "AVI "):
+		return "video/x-msvideo"
+	case hasMagic(head, 0, "\x1a\x45\xdf\xa3"):
+		if bytes.Contains(head, []byte("webm")) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	case hasMagic(head, 0, "#!"):
+		line, _, _ := bytes.Cut(head, []byte("\n"))
+		if t, ok := shebangTypes[shebangInterpreter(string(line))]; ok {
+			return t
+		}
+		return "text/x-script"
+	}
+	for _, m := range mimeMagic {
+		if hasMagic(head, m.offset, m.magic) {
+			return m.mime
+		}
+	}
+	iso := make([]byte, 5)
+	if _, err := f.ReadAt(iso, isoMagicAt); err == nil && string(iso) == "CD001" {
+		return "application/x-iso9660-image"
+	}
+
+	m, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if m == "text/xml" && bytes.Contains(head, []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return m
+}
+
+// zipMIME tells zip-based formats apart by the members they contain.
+func zipMIME(f *os.File, size int64) string {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return mimeZip
+	}
+	for _, member := range zr.File {
+		switch {
+		case member.Name == "mimetype":
+			if data, err := readZipPart(member); err == nil && len(data) > 0 && len(data) < 128 {
+				return strings.TrimSpace(string(data))
+			}
+		case member.Name == "AndroidManifest.xml":
+			return "application/vnd.android.package-archive"
+		case member.Name == "META-INF/MANIFEST.MF":
+			return "application/java-archive"
+		}
+		for prefix, t := range ooxmlTypes {
+			if strings.HasPrefix(member.Name, prefix) {
+				return t
+			}
+		}
+	}
+	return mimeZip
+}
+
+// refineText looks up the extension of a plain text file, keeping the
+// result only when it describes text.
+func refineText(name string) string {
+	m, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(name)))
+	for _, hint := range textualHints {
+		if strings.Contains(m, hint) {
+			return m
+		}
+	}
+	return mimeTextPlain
+}
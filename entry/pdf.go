@@ -0,0 +1,776 @@
+package entry
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf16"
+)
+
+const (
+	pdfTailLen   = 4 << 10  // How much of the file end to search for startxref
+	pdfMaxDepth  = 32       // Nesting, reference chain and xref section limit
+	pdfMaxStream = 64 << 20 // Largest stream decoded
+	pdfPeekLen   = 32       // Lookahead for "gen R" after an integer
+
+	pdfMaxColors           = 32 // Limits of PNG predictor parameters
+	pdfMaxBitsPerComponent = 16
+)
+
+var (
+	errPDFSyntax = errors.New("malformed PDF")
+	errPDFFilter = errors.New("unsupported PDF stream filter")
+)
+
+// PDF object types. Integers are int64, reals float64, strings Go strings
+// holding the raw bytes, and null is nil.
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte
+	}
+)
+
+// pdfXref locates an object: at a file offset, or for objects stored in
+// a compressed object stream, at an index within that stream.
+type pdfXref struct {
+	offset int64
+	stream int
+	free   bool
+}
+
+// pdfFile reads objects on demand through the cross-reference table, so
+// only the trailer, catalog, page tree root and Info dictionary are parsed.
+type pdfFile struct {
+	r       io.ReaderAt
+	size    int64
+	xref    map[int]pdfXref
+	trailer pdfDict
+	objects map[int]any
+	objStms map[int][]byte
+}
+
+// parsePDF reads the page count from the page tree and the title, author
+// and producer from the Info dictionary. Strings of encrypted files are
+// not decrypted and are left empty.
+func parsePDF(r io.ReaderAt, size int64) docInfo {
+	p := &pdfFile{
+		r:       r,
+		size:    size,
+		xref:    make(map[int]pdfXref),
+		trailer: make(pdfDict),
+		objects: make(map[int]any),
+		objStms: make(map[int][]byte),
+	}
+	start, ok := p.startXref()
+	if !ok {
+		return docInfo{}
+	}
+	p.loadXref(start, make(map[int64]bool))
+
+	var d docInfo
+	if catalog, ok := p.resolve(p.trailer["Root"]).(pdfDict); ok {
+		if pages, ok := p.resolve(catalog["Pages"]).(pdfDict); ok {
+			if n, ok := p.resolve(pages["Count"]).(int64); ok {
+				d.count, d.unit = int(n), unitPage
+			}
+		}
+	}
+	if _, encrypted := p.trailer["Encrypt"]; encrypted {
+		return d
+	}
+	if info, ok := p.resolve(p.trailer["Info"]).(pdfDict); ok {
+		d.title = pdfText(p.resolve(info["Title"]))
+		d.author = pdfText(p.resolve(info["Author"]))
+		d.producer = pdfText(p.resolve(info["Producer"]))
+	}
+	return d
+}
+
+// startXref reads the offset of the last cross-reference section.
+func (p *pdfFile) startXref() (int64, bool) {
+	n := min(p.size, pdfTailLen)
+	tail := make([]byte, n)
+	if _, err := p.r.ReadAt(tail, p.size-n); err != nil && err != io.EOF {
+		return 0, false
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return 0, false
+	}
+	fields := bytes.Fields(tail[i+len("startxref"):])
+	if len(fields) == 0 {
+		return 0, false
+	}
+	off, err := strconv.ParseInt(string(fields[0]), 10, 64)
+	return off, err == nil
+}
+
+// loadXref reads the cross-reference section at off and the sections it
+// chains to. Sections are visited newest first, so entries already
+// present are kept.
+func (p *pdfFile) loadXref(off int64, seen map[int64]bool) {
+	if seen[off] || len(seen) > pdfMaxDepth || off < 0 || off >= p.size {
+		return
+	}
+	seen[off] = true
+	l := p.lexerAt(off)
+	l.skipSpace()
+	var trailer pdfDict
+	if b, _ := l.r.Peek(4); string(b) == "xref" {
+		l.r.Discard(4)
+		trailer = p.xrefTable(l)
+	} else {
+		trailer = p.xrefStream(off)
+	}
+	for k, v := range trailer {
+		if _, ok := p.trailer[k]; !ok {
+			p.trailer[k] = v
+		}
+	}
+	if stm, ok := trailer["XRefStm"].(int64); ok {
+		p.loadXref(stm, seen)
+	}
+	if prev, ok := trailer["Prev"].(int64); ok {
+		p.loadXref(prev, seen)
+	}
+}
+
+// xrefTable reads the subsections of a classic xref table up to and
+// including its trailer dictionary.
+func (p *pdfFile) xrefTable(l *pdfLexer) pdfDict {
+	for {
+		v, err := l.value(0)
+		if err != nil {
+			return nil
+		}
+		if v == pdfKeyword("trailer") {
+			trailer, _ := l.value(0)
+			d, _ := trailer.(pdfDict)
+			return d
+		}
+		start, ok := v.(int64)
+		n, err := l.value(0)
+		count, ok2 := n.(int64)
+		if !ok || !ok2 || err != nil {
+			return nil
+		}
+		for i := range count {
+			off, err1 := l.value(0)
+			_, err2 := l.value(0) // Generation
+			kind, err3 := l.value(0)
+			if err1 != nil || err2 != nil || err3 != nil {
+				return nil
+			}
+			num := int(start + i)
+			if _, ok := p.xref[num]; !ok {
+				offset, _ := off.(int64)
+				p.xref[num] = pdfXref{offset: offset, free: kind == pdfKeyword("f")}
+			}
+		}
+	}
+}
+
+// xrefStream reads a cross-reference stream and returns its dictionary,
+// which doubles as the trailer.
+func (p *pdfFile) xrefStream(off int64) pdfDict {
+	v, err := p.objectAt(off)
+	s, ok := v.(pdfStream)
+	if err != nil || !ok || s.dict["Type"] != pdfName("XRef") {
+		return nil
+	}
+	data, err := p.decode(s)
+	if err != nil {
+		return nil
+	}
+	w, _ := s.dict["W"].([]any)
+	if len(w) != 3 {
+		return nil
+	}
+	var widths [3]int
+	for i, v := range w {
+		n, ok := v.(int64)
+		if !ok || n < 0 || n > 8 {
+			return nil
+		}
+		widths[i] = int(n)
+	}
+	entryLen := widths[0] + widths[1] + widths[2]
+	index, _ := s.dict["Index"].([]any)
+	if index == nil {
+		index = []any{int64(0), s.dict["Size"]}
+	}
+	for i := 0; i+1 < len(index) && entryLen > 0; i += 2 {
+		start, ok1 := index[i].(int64)
+		count, ok2 := index[i+1].(int64)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		for j := int64(0); j < count && len(data) >= entryLen; j++ {
+			var fields [3]int64
+			pos := 0
+			for k, width := range widths {
+				for _, b := range data[pos : pos+width] {
+					fields[k] = fields[k]<<8 | int64(b)
+				}
+				pos += width
+			}
+			data = data[entryLen:]
+			if widths[0] == 0 {
+				fields[0] = 1 // Type defaults to an uncompressed object
+			}
+			num := int(start + j)
+			if _, ok := p.xref[num]; ok {
+				continue
+			}
+			switch fields[0] {
+			case 0:
+				p.xref[num] = pdfXref{free: true}
+			case 1:
+				p.xref[num] = pdfXref{offset: fields[1]}
+			case 2:
+				p.xref[num] = pdfXref{stream: int(fields[1]), offset: fields[2]}
+			}
+		}
+	}
+	return s.dict
+}
+
+// resolve follows indirect references to a direct object.
+func (p *pdfFile) resolve(v any) any {
+	for range pdfMaxDepth {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = p.object(ref.num)
+	}
+	return nil
+}
+
+// object returns the indirect object num, or nil if it cannot be read.
+func (p *pdfFile) object(num int) any {
+	if v, ok := p.objects[num]; ok {
+		return v
+	}
+	p.objects[num] = nil // Breaks reference cycles
+	var v any
+	if x, ok := p.xref[num]; ok && !x.free {
+		if x.stream == 0 {
+			v, _ = p.objectAt(x.offset)
+		} else {
+			v = p.compressedObject(x.stream, int(x.offset))
+		}
+	}
+	p.objects[num] = v
+	return v
+}
+
+// objectAt parses the indirect object "num gen obj" at off, reading the
+// stream data that follows a dictionary.
+func (p *pdfFile) objectAt(off int64) (any, error) {
+	if off < 0 || off >= p.size {
+		return nil, errPDFSyntax
+	}
+	l := p.lexerAt(off)
+	for range 2 {
+		if v, err := l.value(0); err != nil {
+			return nil, err
+		} else if _, ok := v.(int64); !ok {
+			return nil, errPDFSyntax
+		}
+	}
+	if kw, err := l.value(0); err != nil || kw != pdfKeyword("obj") {
+		return nil, errPDFSyntax
+	}
+	v, err := l.value(0)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := v.(pdfDict)
+	if !ok {
+		return v, nil
+	}
+	l.skipSpace()
+	if b, _ := l.r.Peek(6); string(b) != "stream" {
+		return dict, nil
+	}
+	l.r.Discard(6)
+	if c, _ := l.r.ReadByte(); c == '\r' {
+		if b, _ := l.r.Peek(1); len(b) == 1 && b[0] == '\n' {
+			l.r.ReadByte()
+		}
+	} else if c != '\n' {
+		l.r.UnreadByte()
+	}
+	n, ok := p.resolve(dict["Length"]).(int64)
+	if !ok || n < 0 || n > pdfMaxStream {
+		return nil, errPDFSyntax
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(l.r, data); err != nil {
+		return nil, err
+	}
+	return pdfStream{dict: dict, data: data}, nil
+}
+
+// compressedObject returns object number index of object stream num.
+func (p *pdfFile) compressedObject(num, index int) any {
+	data, ok := p.objStms[num]
+	if !ok {
+		if s, isStream := p.object(num).(pdfStream); isStream {
+			data, _ = p.decode(s)
+		}
+		p.objStms[num] = data
+	}
+	s, _ := p.objects[num].(pdfStream)
+	n, _ := s.dict["N"].(int64)
+	first, _ := s.dict["First"].(int64)
+	if index < 0 || int64(index) >= n {
+		return nil
+	}
+	l := newPDFLexer(bytes.NewReader(data))
+	var off int64 = -1
+	for i := 0; i <= index; i++ {
+		_, err1 := l.value(0) // Object number
+		v, err2 := l.value(0)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		off, _ = v.(int64)
+	}
+	// Both come from the file; compare them separately so the sum cannot wrap.
+	if off < 0 || first < 0 || first > int64(len(data)) || off >= int64(len(data))-first {
+		return nil
+	}
+	v, _ := newPDFLexer(bytes.NewReader(data[first+off:])).value(0)
+	return v
+}
+
+// decode applies the stream's filter. Only FlateDecode, optionally with a
+// PNG predictor, is supported, which covers object and xref streams.
+func (p *pdfFile) decode(s pdfStream) ([]byte, error) {
+	filter, parms := p.resolve(s.dict["Filter"]), p.resolve(s.dict["DecodeParms"])
+	if a, ok := filter.([]any); ok {
+		if len(a) != 1 {
+			return nil, errPDFFilter
+		}
+		filter = a[0]
+		if a, ok := parms.([]any); ok && len(a) == 1 {
+			parms = a[0]
+		}
+	}
+	switch filter {
+	case nil:
+		return s.data, nil
+	case pdfName("FlateDecode"):
+	default:
+		return nil, errPDFFilter
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(zr, pdfMaxStream))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if d, ok := parms.(pdfDict); ok {
+		return pdfUnpredict(data, d)
+	}
+	return data, nil
+}
+
+// pdfUnpredict reverses a PNG predictor, which prefixes each row with its
+// filter type.
+func pdfUnpredict(data []byte, parms pdfDict) ([]byte, error) {
+	predictor, _ := parms["Predictor"].(int64)
+	switch {
+	case predictor <= 1:
+		return data, nil
+	case predictor < 10:
+		return nil, errPDFFilter // TIFF predictor
+	}
+	param := func(key pdfName, def int64) int64 {
+		if v, ok := parms[key].(int64); ok && v > 0 {
+			return v
+		}
+		return def
+	}
+	colors, bpc, columns := param("Colors", 1), param("BitsPerComponent", 8), param("Columns", 1)
+	if colors > pdfMaxColors || bpc > pdfMaxBitsPerComponent || columns > int64(len(data)) {
+		return nil, errPDFSyntax
+	}
+	rowLen := int((columns*colors*bpc + 7) / 8)
+	if rowLen >= len(data) {
+		return nil, errPDFSyntax // Not even one row
+	}
+	bpp := max(1, int(colors*bpc/8))
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for len(data) > rowLen {
+		kind := data[0]
+		row := append([]byte(nil), data[1:rowLen+1]...)
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev, data = row, data[rowLen+1:]
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(n int) int { return max(n, -n) }
+
+// pdfText decodes a text string, which is UTF-16BE when it starts with a
+// byte order mark, UTF-8 with one, and PDFDocEncoding otherwise.
+func pdfText(v any) string {
+	s, ok := v.(string)
+	if !ok {
+		return ""
+	}
+	b := []byte(s)
+	switch {
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return cleanText(string(utf16.Decode(units)))
+	case bytes.HasPrefix(b, []byte(utf8BOM)):
+		return cleanText(s[len(utf8BOM):])
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c) // PDFDocEncoding matches Latin-1 for printable text
+	}
+	return cleanText(string(runes))
+}
+
+// pdfLexer reads PDF objects from a byte stream.
+type pdfLexer struct {
+	r *bufio.Reader
+}
+
+func newPDFLexer(r io.Reader) *pdfLexer {
+	return &pdfLexer{r: bufio.NewReader(r)}
+}
+
+func (p *pdfFile) lexerAt(off int64) *pdfLexer {
+	return newPDFLexer(io.NewSectionReader(p.r, off, p.size-off))
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isPDFRegular(c byte) bool { return !isPDFSpace(c) && !isPDFDelim(c) }
+
+// skipSpace skips white space and comments.
+func (l *pdfLexer) skipSpace() {
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return
+		}
+		if c == '%' {
+			for c != '\n' && c != '\r' {
+				if c, err = l.r.ReadByte(); err != nil {
+					return
+				}
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			l.r.UnreadByte()
+			return
+		}
+	}
+}
+
+// value reads the next object. Keywords other than true, false and null
+// are returned as pdfKeyword.
+func (l *pdfLexer) value(depth int) (any, error) {
+	if depth > pdfMaxDepth {
+		return nil, errPDFSyntax
+	}
+	l.skipSpace()
+	c, err := l.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == '/':
+		return l.name(), nil
+	case c == '(':
+		return l.literal()
+	case c == '<':
+		if b, _ := l.r.Peek(1); len(b) == 1 && b[0] == '<' {
+			l.r.ReadByte()
+			return l.dict(depth)
+		}
+		return l.hex()
+	case c == '[':
+		return l.array(depth)
+	case c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9':
+		l.r.UnreadByte()
+		return l.number()
+	case isPDFDelim(c):
+		return nil, errPDFSyntax
+	}
+	l.r.UnreadByte()
+	switch word := l.word(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return pdfKeyword(word), nil
+	}
+}
+
+func (l *pdfLexer) word() string {
+	var b []byte
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			break
+		}
+		if !isPDFRegular(c) {
+			l.r.UnreadByte()
+			break
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+// name reads a name after its slash, decoding #xx escapes.
+func (l *pdfLexer) name() pdfName {
+	raw := l.word()
+	var b []byte
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(v))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, raw[i])
+	}
+	return pdfName(b)
+}
+
+// number reads an integer, a real, or an indirect reference "num gen R".
+func (l *pdfLexer) number() (any, error) {
+	word := l.word()
+	n, err := strconv.ParseInt(word, 10, 64)
+	if err != nil {
+		f, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return nil, errPDFSyntax
+		}
+		return f, nil
+	}
+	if n >= 0 {
+		if gen, ok := l.refSuffix(); ok {
+			return pdfRef{num: int(n), gen: gen}, nil
+		}
+	}
+	return n, nil
+}
+
+// refSuffix consumes " gen R" if it follows.
+func (l *pdfLexer) refSuffix() (int, bool) {
+	b, _ := l.r.Peek(pdfPeekLen)
+	i := 0
+	skip := func() int {
+		start := i
+		for i < len(b) && isPDFSpace(b[i]) {
+			i++
+		}
+		return i - start
+	}
+	if skip() == 0 {
+		return 0, false
+	}
+	start := i
+	for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+		i++
+	}
+	if i == start {
+		return 0, false
+	}
+	gen, _ := strconv.Atoi(string(b[start:i]))
+	if skip() == 0 || i >= len(b) || b[i] != 'R' || i+1 < len(b) && isPDFRegular(b[i+1]) {
+		return 0, false
+	}
+	l.r.Discard(i + 1)
+	return gen, true
+}
+
+// literal reads a parenthesized string after its opening parenthesis.
+func (l *pdfLexer) literal() (any, error) {
+	var b []byte
+	nesting := 1
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			if nesting--; nesting == 0 {
+				return string(b), nil
+			}
+		case '\\':
+			if c, err = l.r.ReadByte(); err != nil {
+				return nil, err
+			}
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n': // Line continuation
+				if next, _ := l.r.Peek(1); c == '\r' && len(next) == 1 && next[0] == '\n' {
+					l.r.ReadByte()
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := c - '0'
+					for range 2 {
+						next, _ := l.r.Peek(1)
+						if len(next) == 0 || next[0] < '0' || next[0] > '7' {
+							break
+						}
+						l.r.ReadByte()
+						v = v<<3 | (next[0] - '0')
+					}
+					c = v
+				}
+			}
+		}
+		b = append(b, c)
+	}
+}
+
+// hex reads a hexadecimal string after its opening angle bracket.
+func (l *pdfLexer) hex() (any, error) {
+	var digits []byte
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == '>' {
+			break
+		}
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 != 0 {
+		digits = append(digits, '0')
+	}
+	b, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return nil, errPDFSyntax
+	}
+	return string(b), nil
+}
+
+func (l *pdfLexer) array(depth int) (any, error) {
+	var a []any
+	for {
+		l.skipSpace()
+		if b, _ := l.r.Peek(1); len(b) == 1 && b[0] == ']' {
+			l.r.ReadByte()
+			return a, nil
+		}
+		v, err := l.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+}
+
+func (l *pdfLexer) dict(depth int) (any, error) {
+	d := make(pdfDict)
+	for {
+		l.skipSpace()
+		if b, _ := l.r.Peek(2); string(b) == ">>" {
+			l.r.Discard(2)
+			return d, nil
+		}
+		key, err := l.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, errPDFSyntax
+		}
+		v, err := l.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		d[name] = v
+	}
+}
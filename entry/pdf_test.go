@@ -0,0 +1,32 @@
+package entry
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompressedObjectBadOffsets(t *testing.T) {
+	data := []byte("7 0 8 4 (a) (b)")
+	for _, tc := range []struct {
+		name  string
+		first int64
+		index int
+		want  any
+	}{
+		{"valid", 8, 1, "b"},
+		{"negative first", -50, 1, nil},
+		{"first past data", int64(len(data)) + 1, 0, nil},
+		{"sum wraps", math.MaxInt64, 1, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &pdfFile{
+				objects: map[int]any{1: pdfStream{dict: pdfDict{"N": int64(2), "First": tc.first}}},
+				objStms: map[int][]byte{1: data},
+			}
+			got := p.compressedObject(1, tc.index)
+			if tc.want == nil && got != nil || tc.want != nil && pdfText(got) != tc.want {
+				t.Errorf("compressedObject() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package entry
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scaleFieldAge  = "age"
+	scaleFieldSize = "size"
+	scaleFieldName = "name"
+
+	ageScaleMin = time.Minute              // Newer entries get the age-new color
+	ageScaleMax = 5 * 365 * 24 * time.Hour // Older entries get the age-old color
+)
+
+// scaleFields lists the fields accepted by --color-scale.
+var scaleFields = []string{
+	scaleFieldAge,
+	scaleFieldSize,
+	scaleFieldName,
+}
+
+// colorScale records which fields --color-scale colors on a gradient.
+var colorScale struct {
+	age, size, name bool
+}
+
+// parseColorScale validates a comma-separated list of scale fields and
+// stores it in colorScale.
+func parseColorScale(s string) error {
+	for _, field := range strings.Split(s, ",") {
+		switch strings.TrimSpace(field) {
+		case scaleFieldAge:
+			colorScale.age = true
+		case scaleFieldSize:
+			colorScale.size = true
+		case scaleFieldName:
+			colorScale.name = true
+		default:
+			return fmt.Errorf("invalid color scale field %q (valid: %s)", field, strings.Join(scaleFields, ", "))
+		}
+	}
+	return nil
+}
+
+// ageFraction places a time on a logarithmic scale from a minute ago (0)
+// to five years ago (1), so recent changes are spread out the most.
+func ageFraction(t time.Time) float64 {
+	age := time.Since(t)
+	if age <= ageScaleMin {
+		return 0
+	}
+	return min(math.Log(float64(age)/float64(ageScaleMin))/math.Log(float64(ageScaleMax)/float64(ageScaleMin)), 1)
+}
+
+// sizeFraction places a size on a logarithmic scale up to the largest
+// size in the listing.
+func sizeFraction(size, largest int64) float64 {
+	if size <= 0 || largest <= 0 {
+		return 0
+	}
+	return min(math.Log1p(float64(size))/math.Log1p(float64(largest)), 1)
+}
+
+// largestSize returns the size of the largest regular file in entries.
+func largestSize(entries []Entry) int64 {
+	var largest int64
+	for _, e := range entries {
+		if e.Mode().IsRegular() {
+			largest = max(largest, e.Size())
+		}
+	}
+	return largest
+}
+
+// age colors a modification time, on the age gradient with
+// --color-scale=age.
+func (c colorizer) age(t time.Time, text string) string {
+	if !colorScale.age {
+		return c.modTime(text)
+	}
+	return c.gradient(text, c.theme.ageNew, c.theme.ageOld, ageFraction(t))
+}
+
+// scaledSize formats a size, colored relative to the largest size in the
+// listing with --color-scale=size and by fixed unit bands otherwise.
+func scaledSize(size, largest int64) string {
+	if !colorScale.size {
+		return formatSize(size)
+	}
+	text, _ := humanSize(size)
+	return color.gradient(text, color.theme.sizeSmall, color.theme.sizeLarge, sizeFraction(size, largest))
+}
+
+// gradient colors text at fraction t between two styles. Styles without a
+// foreground color cannot be blended, so the nearer one is used instead.
+func (c colorizer) gradient(text, from, to string, t float64) string {
+	a, okFrom := sgrRGB(from)
+	b, okTo := sgrRGB(to)
+	if !okFrom || !okTo {
+		if t < 0.5 {
+			return c.colorize(text, from)
+		}
+		return c.colorize(text, to)
+	}
+	var mix [3]int
+	for i := range mix {
+		mix[i] = int(math.Round(float64(a[i]) + t*float64(b[i]-a[i])))
+	}
+	code := fmt.Sprintf("%s%d;%d;%d", sgrFgTrueColor, mix[0], mix[1], mix[2])
+	return c.colorize(text, downsample(code, c.depth))
+}
+
+// sgrRGB returns the RGB value of the foreground color in SGR parameters.
+func sgrRGB(code string) ([3]int, bool) {
+	params := strings.Split(code, ";")
+	for i := 0; i < len(params); i++ {
+		n, err := strconv.Atoi(params[i])
+		switch {
+		case err != nil:
+			return [3]int{}, false
+		case n >= sgrFgBasic && n < sgrFgBasic+8:
+			return ansi16[n-sgrFgBasic], true
+		case n >= sgrFgBright && n < sgrFgBright+8:
+			return ansi16[n-sgrFgBright+8], true
+		case (n == 38 || n == 48) && i+1 < len(params):
+			var rgb [3]int
+			var ok bool
+			switch params[i+1] {
+			case sgrExtendedColor:
+				if i+2 < len(params) {
+					index, err := strconv.Atoi(params[i+2])
+					rgb, ok = paletteRGB(index), err == nil
+				}
+				i += 2
+			case sgrTrueColor:
+				ok = i+4 < len(params)
+				for j := range rgb {
+					if ok {
+						rgb[j], err = strconv.Atoi(params[i+2+j])
+						ok = err == nil
+					}
+				}
+				i += 4
+			}
+			if n == 38 {
+				return rgb, ok
+			}
+		}
+	}
+	return [3]int{}, false
+}
@@ -7,22 +7,90 @@ import (
 	"strings"
 )
 
+const (
+	sortKeyName       = "name"
+	sortKeySize       = "size"
+	sortKeyTime       = "time"
+	sortKeyKind       = "kind"
+	sortKeyExt        = "extension"
+	sortKeyCommit     = "commit"
+	sortKeyDuration   = "duration"
+	sortKeyResolution = "resolution"
+	sortKeyFrameRate  = "framerate"
+	sortKeyCodec      = "codec"
+	sortKeyPixels     = "pixels"
+	sortKeyTaken      = "taken"
+	sortKeyExpiry     = "expiry"
+)
+
+// sortKeys lists the keys accepted by --sort.
+var sortKeys = []string{
+	sortKeyName,
+	sortKeySize,
+	sortKeyTime,
+	sortKeyKind,
+	sortKeyExt,
+	sortKeyCommit,
+	sortKeyDuration,
+	sortKeyResolution,
+	sortKeyFrameRate,
+	sortKeyCodec,
+	sortKeyPixels,
+	sortKeyTaken,
+	sortKeyExpiry,
+}
+
 type sortableEntry struct {
 	entry    Entry
 	lower    string
 	extLower string
 }
 
-func sortEntries(entries []Entry) {
+// sortKey returns the active sort key. --sort takes precedence over
+// the single-letter sort flags.
+func sortKey() string {
 	switch {
+	case cfg.Sort != "":
+		return cfg.Sort
 	case cfg.Size:
-		sortBySize(entries)
+		return sortKeySize
 	case cfg.Time:
-		sortByTime(entries)
+		return sortKeyTime
 	case cfg.Kind:
-		sortByKind(entries)
+		return sortKeyKind
 	case cfg.Ext:
+		return sortKeyExt
+	default:
+		return sortKeyName
+	}
+}
+
+func sortEntries(entries []Entry) {
+	switch sortKey() {
+	case sortKeySize:
+		sortBySize(entries)
+	case sortKeyTime:
+		sortByTime(entries)
+	case sortKeyKind:
+		sortByKind(entries)
+	case sortKeyExt:
 		sortByExt(entries)
+	case sortKeyCommit:
+		sortByCommit(entries)
+	case sortKeyDuration:
+		sortByDuration(entries)
+	case sortKeyResolution:
+		sortByResolution(entries)
+	case sortKeyFrameRate:
+		sortByFrameRate(entries)
+	case sortKeyCodec:
+		sortByCodec(entries)
+	case sortKeyPixels:
+		sortByPixels(entries)
+	case sortKeyTaken:
+		sortByTaken(entries)
+	case sortKeyExpiry:
+		sortByExpiry(entries)
 	default:
 		sortByName(entries)
 	}
@@ -59,6 +127,80 @@ func sortByTime(entries []Entry) {
 	})
 }
 
+func sortByCommit(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		ac, _ := lastCommit(a)
+		bc, _ := lastCommit(b)
+		return cmp.Compare(bc.time.Unix(), ac.time.Unix())
+	})
+}
+
+func sortByDuration(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(probeMedia(b).duration, probeMedia(a).duration)
+	})
+}
+
+func sortByResolution(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		am, bm := probeMedia(a), probeMedia(b)
+		return cmp.Compare(bm.width*bm.height, am.width*am.height)
+	})
+}
+
+func sortByFrameRate(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(probeMedia(b).frameRate, probeMedia(a).frameRate)
+	})
+}
+
+// sortByCodec groups entries by codec name, leaving non-media files last.
+func sortByCodec(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		ac, bc := probeMedia(a).codec, probeMedia(b).codec
+		if (ac == "") != (bc == "") {
+			return cmp.Compare(bc, ac)
+		}
+		return cmp.Compare(ac, bc)
+	})
+}
+
+func sortByPixels(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(probeImage(b).pixels(), probeImage(a).pixels())
+	})
+}
+
+// sortByTaken orders photos by capture date, newest first, leaving files
+// without one last.
+func sortByTaken(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		at, bt := probeEXIF(a).taken, probeEXIF(b).taken
+		switch {
+		case at.IsZero() && !bt.IsZero():
+			return 1
+		case bt.IsZero() && !at.IsZero():
+			return -1
+		}
+		return bt.Compare(at)
+	})
+}
+
+// sortByExpiry orders certificates by expiry, soonest first, leaving
+// other files last.
+func sortByExpiry(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		at, bt := probeCert(a).notAfter, probeCert(b).notAfter
+		switch {
+		case at.IsZero() && !bt.IsZero():
+			return 1
+		case bt.IsZero() && !at.IsZero():
+			return -1
+		}
+		return at.Compare(bt)
+	})
+}
+
 func sortByKind(entries []Entry) {
 	if len(entries) <= 1 {
 		return
@@ -0,0 +1,287 @@
+package entry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+const (
+	headerLines    = "Lines"
+	headerEncoding = "Encoding"
+	headerBOM      = "BOM"
+	headerEndings  = "Endings"
+	headerFinalNL  = "Final NL"
+
+	encodingASCII   = "ASCII"
+	encodingUTF8    = "UTF-8"
+	encodingUTF16LE = "UTF-16LE"
+	encodingUTF16BE = "UTF-16BE"
+	encodingLatin1  = "Latin-1"
+
+	endingLF    = "LF"
+	endingCRLF  = "CRLF"
+	endingCR    = "CR"
+	endingMixed = "mixed"
+
+	labelStray = "stray"
+
+	binarySniffLen = 8000 // As in git: a NUL in this prefix marks a binary file
+	textChunkLen   = 64 << 10
+	utf16LEBOM     = "\xff\xfe"
+	utf16BEBOM     = "\xfe\xff"
+)
+
+// textInfo holds statistics of a text file. isText is false for binary
+// files and directories, whose other fields are then meaningless.
+type textInfo struct {
+	isText   bool
+	lines    int
+	encoding string
+	bom      bool // Leading byte order mark
+	strayBOM bool // U+FEFF after the start of the file
+	lf       int
+	crlf     int
+	cr       int
+	finalNL  bool
+	nonEmpty bool
+}
+
+// endings names the line terminators in use, or "" for a single line.
+func (t textInfo) endings() string {
+	var kinds []string
+	for _, k := range []struct {
+		count int
+		name  string
+	}{{t.lf, endingLF}, {t.crlf, endingCRLF}, {t.cr, endingCR}} {
+		if k.count > 0 {
+			kinds = append(kinds, k.name)
+		}
+	}
+	switch len(kinds) {
+	case 0:
+		return ""
+	case 1:
+		return kinds[0]
+	}
+	return endingMixed
+}
+
+var textCache = make(map[string]textInfo)
+
+func textColumns() []column {
+	return []column{
+		{header: headerLines, rightAlign: true, value: func(e Entry) string {
+			t := probeText(e)
+			if !t.isText {
+				return color.placeholder(placeholderField)
+			}
+			return color.media(fmt.Sprint(t.lines))
+		}},
+		{header: headerEncoding, value: func(e Entry) string {
+			t := probeText(e)
+			if !t.isText {
+				return color.placeholder(placeholderField)
+			}
+			return textField(t.encoding, color.media)
+		}},
+		{header: headerBOM, value: func(e Entry) string {
+			t := probeText(e)
+			switch {
+			case !t.isText || !t.bom && !t.strayBOM:
+				return color.placeholder(placeholderField)
+			case t.bom && t.strayBOM:
+				return color.warning(labelYes + "+" + labelStray)
+			case t.strayBOM:
+				return color.warning(labelStray)
+			}
+			return color.media(labelYes)
+		}},
+		{header: headerEndings, value: func(e Entry) string {
+			t := probeText(e)
+			if !t.isText {
+				return color.placeholder(placeholderField)
+			}
+			endings := t.endings()
+			if endings == endingMixed {
+				return color.warning(endings)
+			}
+			return textField(endings, color.media)
+		}},
+		{header: headerFinalNL, value: func(e Entry) string {
+			t := probeText(e)
+			switch {
+			case !t.isText || !t.nonEmpty:
+				return color.placeholder(placeholderField)
+			case t.finalNL:
+				return color.media(labelYes)
+			}
+			return color.warning(labelNo)
+		}},
+	}
+}
+
+// probeText reads a whole file to count lines and line terminators.
+// Results are cached per path.
+func probeText(e Entry) textInfo {
+	if t, ok := textCache[e.path]; ok {
+		return t
+	}
+	var t textInfo
+	if f, err := openEntry(e); err == nil {
+		t = scanText(f)
+		f.Close()
+	}
+	textCache[e.path] = t
+	return t
+}
+
+func scanText(f *os.File) textInfo {
+	head := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return textInfo{}
+	}
+	head = head[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return textInfo{}
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte(utf16LEBOM)):
+		return scanUTF16(f, encodingUTF16LE)
+	case bytes.HasPrefix(head, []byte(utf16BEBOM)):
+		return scanUTF16(f, encodingUTF16BE)
+	case bytes.IndexByte(head, 0) >= 0:
+		return textInfo{}
+	}
+	return scanBytes(f)
+}
+
+// scanBytes scans an 8-bit or UTF-8 file. The encoding is UTF-8 when every
+// sequence is valid and Latin-1 otherwise.
+func scanBytes(r io.Reader) textInfo {
+	t := textInfo{isText: true}
+	var pending []byte // Incomplete UTF-8 sequence carried to the next chunk
+	var ascii, valid = true, true
+	var last byte
+	buf := make([]byte, textChunkLen)
+	for first := true; ; first = false {
+		n, err := r.Read(buf)
+		chunk := buf[:n]
+		if first && bytes.HasPrefix(chunk, []byte(utf8BOM)) {
+			t.bom = true
+		}
+		if n > 0 {
+			t.nonEmpty = true
+			for _, c := range chunk {
+				t.countByte(c, last)
+				last = c
+				if c >= utf8.RuneSelf {
+					ascii = false
+				}
+			}
+			if valid {
+				b := append(pending, chunk...)
+				cut := completeUTF8(b)
+				valid = utf8.Valid(b[:cut])
+				offset := 0
+				if first && t.bom {
+					offset = len(utf8BOM)
+				}
+				if bytes.Contains(b[offset:cut], []byte(utf8BOM)) {
+					t.strayBOM = true
+				}
+				pending = append([]byte(nil), b[cut:]...)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	t.finish(rune(last))
+	switch {
+	case !t.nonEmpty:
+	case valid && len(pending) == 0 && ascii:
+		t.encoding = encodingASCII
+	case valid && len(pending) == 0:
+		t.encoding = encodingUTF8
+	default:
+		t.encoding, t.strayBOM = encodingLatin1, false
+	}
+	return t
+}
+
+// completeUTF8 returns the length of the prefix of b that does not end in
+// the middle of a UTF-8 sequence.
+func completeUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+// scanUTF16 scans a UTF-16 file with a byte order mark.
+func scanUTF16(r io.Reader, encoding string) textInfo {
+	t := textInfo{isText: true, encoding: encoding, bom: true}
+	var pending []byte
+	var last uint16
+	buf := make([]byte, textChunkLen)
+	for first := true; ; first = false {
+		n, err := r.Read(buf)
+		b := append(pending, buf[:n]...)
+		if first {
+			b = b[min(2, len(b)):]
+		}
+		for ; len(b) >= 2; b = b[2:] {
+			u := uint16(b[0]) | uint16(b[1])<<8
+			if encoding == encodingUTF16BE {
+				u = uint16(b[0])<<8 | uint16(b[1])
+			}
+			t.nonEmpty = true
+			if u == 0xfeff {
+				t.strayBOM = true
+			}
+			t.countByte(byte(min(u, utf8.RuneSelf)), byte(min(last, utf8.RuneSelf)))
+			last = u
+		}
+		pending = append([]byte(nil), b...)
+		if err != nil {
+			break
+		}
+	}
+	t.finish(rune(last))
+	return t
+}
+
+// countByte tallies line terminators; a CR is counted once the next byte
+// shows whether it starts a CRLF.
+func (t *textInfo) countByte(c, last byte) {
+	switch {
+	case c == '\n' && last == '\r':
+		t.crlf++
+	case c == '\n':
+		t.lf++
+	case last == '\r':
+		t.cr++
+	}
+}
+
+// finish counts a trailing CR and the final line when it is unterminated.
+func (t *textInfo) finish(last rune) {
+	if last == '\r' {
+		t.cr++
+	}
+	t.finalNL = last == '\n' || last == '\r'
+	t.lines = t.lf + t.crlf + t.cr
+	if !t.finalNL && t.nonEmpty {
+		t.lines++
+	}
+}
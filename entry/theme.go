@@ -0,0 +1,457 @@
+package entry
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	themeDefault   = "default"
+	themeLight     = "light" // Picked without a theme on light backgrounds
+	themeExt       = ".theme"
+	themeBaseKey   = "base"
+	themeMaxDepth  = 8 // Limits chains of base themes
+	configDirName  = "gaze"
+	themesDirName  = "themes"
+	defaultTheme   = "theme" // Theme file in the config dir applied without --theme
+	sgrBackground  = 10      // Offset from a foreground to a background code
+	sgrFgBasic     = 30
+	sgrFgBright    = 90
+	sgrFgExtended  = "38;5;"
+	sgrFgTrueColor = "38;2;"
+	sgrBgExtended  = "48;5;"
+	sgrBgTrueColor = "48;2;"
+)
+
+// palette holds the SGR parameters of every themable element of a
+// listing. An empty string leaves the element uncolored.
+type palette struct {
+	readPerm    string
+	writePerm   string
+	execPerm    string
+	user        string
+	group       string
+	modTime     string
+	sizeBytes   string
+	sizeKB      string
+	sizeMB      string
+	sizeGB      string
+	sizeTB      string
+	sizePB      string
+	sizeEB      string
+	placeholder string
+	commitHash  string
+	author      string
+	added       string
+	modified    string
+	removed     string
+	duration    string
+	media       string
+	warning     string
+	expired     string
+	expiring    string
+	treePrefix  string
+	ageNew      string // Gradient ends for --color-scale
+	ageOld      string
+	sizeSmall   string
+	sizeLarge   string
+}
+
+var defaultPalette = palette{
+	readPerm:    colorReadPerm,
+	writePerm:   colorWritePerm,
+	execPerm:    colorExecPerm,
+	user:        colorUser,
+	group:       colorGroup,
+	modTime:     colorModTime,
+	sizeBytes:   colorSizeBytes,
+	sizeKB:      colorSizeKB,
+	sizeMB:      colorSizeMB,
+	sizeGB:      colorSizeGB,
+	sizeTB:      colorSizeTB,
+	sizePB:      colorSizePB,
+	sizeEB:      colorSizeEB,
+	placeholder: colorPlaceholder,
+	commitHash:  colorCommitHash,
+	author:      colorAuthor,
+	added:       colorAdded,
+	modified:    colorModified,
+	removed:     colorRemoved,
+	duration:    colorDuration,
+	media:       colorMedia,
+	warning:     colorWarning,
+	expired:     colorExpired,
+	expiring:    colorExpiring,
+	treePrefix:  colorTreePrefix,
+	ageNew:      colorAgeNew,
+	ageOld:      colorAgeOld,
+	sizeSmall:   colorSizeSmall,
+	sizeLarge:   colorSizeLarge,
+}
+
+// fields maps theme file keys to the palette entries they set.
+func (p *palette) fields() map[string]*string {
+	return map[string]*string{
+		"read-perm":   &p.readPerm,
+		"write-perm":  &p.writePerm,
+		"exec-perm":   &p.execPerm,
+		"user":        &p.user,
+		"group":       &p.group,
+		"mod-time":    &p.modTime,
+		"size-bytes":  &p.sizeBytes,
+		"size-kb":     &p.sizeKB,
+		"size-mb":     &p.sizeMB,
+		"size-gb":     &p.sizeGB,
+		"size-tb":     &p.sizeTB,
+		"size-pb":     &p.sizePB,
+		"size-eb":     &p.sizeEB,
+		"placeholder": &p.placeholder,
+		"commit-hash": &p.commitHash,
+		"author":      &p.author,
+		"added":       &p.added,
+		"modified":    &p.modified,
+		"removed":     &p.removed,
+		"duration":    &p.duration,
+		"media":       &p.media,
+		"warning":     &p.warning,
+		"expired":     &p.expired,
+		"expiring":    &p.expiring,
+		"tree-prefix": &p.treePrefix,
+		"age-new":     &p.ageNew,
+		"age-old":     &p.ageOld,
+		"size-small":  &p.sizeSmall,
+		"size-large":  &p.sizeLarge,
+	}
+}
+
+// bundledThemes are the themes available by name without a theme file.
+// Keys they leave out keep the default palette.
+var bundledThemes = map[string]string{
+	themeDefault: "",
+	"solarized": `
+read-perm    #b58900
+write-perm   #dc322f
+exec-perm    #859900
+user         #268bd2
+group        #6c71c4
+mod-time     #2aa198
+size-bytes   #839496
+size-kb      #859900
+size-mb      #b58900
+size-gb      #cb4b16
+size-tb      #dc322f
+size-pb      #d33682
+size-eb      #6c71c4 bold
+placeholder  #586e75
+commit-hash  #b58900
+author       #6c71c4
+added        #859900
+modified     #b58900
+removed      #dc322f
+duration     #2aa198
+media        #839496
+warning      #cb4b16
+expired      #dc322f bold
+expiring     #b58900
+tree-prefix  #586e75
+age-new      #b58900
+age-old      #586e75
+size-small   #586e75
+size-large   #dc322f
+`,
+	themeLight: `
+read-perm    60
+write-perm   97
+exec-perm    54
+user         55
+group        61
+mod-time     59
+size-bytes   243
+size-kb      28
+size-mb      126
+size-gb      125
+size-tb      54
+size-pb      53
+size-eb      90
+placeholder  246
+commit-hash  95
+author       91
+added        28
+modified     130
+removed      124
+duration     29
+media        240
+warning      166
+expired      160 bold
+expiring     136
+tree-prefix  245
+age-new      162
+age-old      250
+size-small   250
+size-large   127
+`,
+	"high-contrast": `
+read-perm    blue bold
+write-perm   red bold
+exec-perm    green bold
+user         magenta bold
+group        cyan bold
+mod-time     bold
+size-bytes   plain
+size-kb      green bold
+size-mb      blue bold
+size-gb      magenta bold
+size-tb      red bold
+size-pb      red bold underline
+size-eb      red bold reverse
+placeholder  plain
+commit-hash  blue bold
+author       magenta bold
+added        green bold
+modified     blue bold
+removed      red bold
+duration     cyan bold
+media        plain
+warning      red bold
+expired      white on red bold
+expiring     black on yellow
+tree-prefix  plain
+age-new      green bold
+age-old      plain
+size-small   plain
+size-large   red bold
+`,
+	"monochrome": `
+read-perm    plain
+write-perm   bold
+exec-perm    bold underline
+user         plain
+group        dim
+mod-time     dim
+size-bytes   plain
+size-kb      plain
+size-mb      bold
+size-gb      bold
+size-tb      bold underline
+size-pb      bold underline
+size-eb      bold reverse
+placeholder  dim
+commit-hash  plain
+author       italic
+added        bold
+modified     italic
+removed      strikethrough
+duration     plain
+media        plain
+warning      underline
+expired      bold reverse
+expiring     bold
+tree-prefix  dim
+age-new      bold
+age-old      dim
+size-small   dim
+size-large   bold
+`,
+}
+
+// sgrAttributes maps style words to SGR parameters.
+var sgrAttributes = map[string]string{
+	"bold":          "1",
+	"dim":           "2",
+	"italic":        "3",
+	"underline":     "4",
+	"blink":         "5",
+	"reverse":       "7",
+	"strikethrough": "9",
+}
+
+// basicColors are the names of the eight standard terminal colors, in
+// SGR order; "bright-" selects their bright variants.
+var basicColors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// configDir returns the directory of gaze's configuration files.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configDirName), nil
+}
+
+// loadTheme returns the palette of a theme given by name or path. With an
+// empty name it applies the theme file in the config dir, if any, and
+// otherwise the theme suiting the terminal background.
+func loadTheme(name string) (palette, error) {
+	if name == "" {
+		dir, err := configDir()
+		if err != nil {
+			return backgroundTheme()
+		}
+		p, err := readThemeFile(filepath.Join(dir, defaultTheme), 0)
+		if errors.Is(err, os.ErrNotExist) {
+			return backgroundTheme()
+		}
+		return p, err
+	}
+	return resolveTheme(name, 0)
+}
+
+// backgroundTheme returns the light theme when colored output goes to a
+// terminal with a light background, and the default palette otherwise.
+func backgroundTheme() (palette, error) {
+	if color.enabled && lightBackground() {
+		return resolveTheme(themeLight, 0)
+	}
+	return defaultPalette, nil
+}
+
+// resolveTheme finds a theme by file path, then in the themes folder of
+// the config dir, then among the bundled themes.
+func resolveTheme(name string, depth int) (palette, error) {
+	if depth > themeMaxDepth {
+		return palette{}, fmt.Errorf("theme %q: too many nested base themes", name)
+	}
+	if strings.ContainsRune(name, filepath.Separator) || strings.HasSuffix(name, themeExt) {
+		return readThemeFile(name, depth)
+	}
+	if dir, err := configDir(); err == nil {
+		p, err := readThemeFile(filepath.Join(dir, themesDirName, name+themeExt), depth)
+		if !errors.Is(err, os.ErrNotExist) {
+			return p, err
+		}
+	}
+	if text, ok := bundledThemes[name]; ok {
+		return parseTheme(strings.NewReader(text), name, depth)
+	}
+	names := slices.Sorted(maps.Keys(bundledThemes))
+	return palette{}, fmt.Errorf("unknown theme %q (bundled: %s)", name, strings.Join(names, ", "))
+}
+
+func readThemeFile(name string, depth int) (palette, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return palette{}, err
+	}
+	defer f.Close()
+	return parseTheme(f, name, depth)
+}
+
+// parseTheme reads a theme: one "key style" pair per line, where the style
+// is a list of attributes and colors, and lines starting with '#' are
+// comments. A leading "base NAME" line starts from another theme instead
+// of the default palette.
+func parseTheme(r io.Reader, name string, depth int) (palette, error) {
+	p := defaultPalette
+	entries := p.fields()
+	sc := bufio.NewScanner(r)
+	for lineNo, first := 1, true; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		key, value := fields[0], strings.Join(fields[1:], " ")
+		if key == themeBaseKey && first {
+			base, err := resolveTheme(value, depth+1)
+			if err != nil {
+				return palette{}, fmt.Errorf("%s:%d: %v", name, lineNo, err)
+			}
+			p = base
+			entries = p.fields()
+			first = false
+			continue
+		}
+		first = false
+		field, ok := entries[key]
+		if !ok {
+			return palette{}, fmt.Errorf("%s:%d: unknown key %q", name, lineNo, key)
+		}
+		code, err := parseStyle(value)
+		if err != nil {
+			return palette{}, fmt.Errorf("%s:%d: %v", name, lineNo, err)
+		}
+		*field = code
+	}
+	if err := sc.Err(); err != nil {
+		return palette{}, err
+	}
+	return p, nil
+}
+
+// parseStyle converts a style such as "bold #ff8800 on black" into SGR
+// parameters. Colors are basic names, "bright-" names, 256-color indexes,
+// #rrggbb for 24-bit color or raw parameters like "38;5;97". "plain"
+// clears the style.
+func parseStyle(style string) (string, error) {
+	var codes []string
+	background := false
+	for _, word := range strings.Fields(strings.ToLower(style)) {
+		if word == "on" {
+			background = true
+			continue
+		}
+		if attr, ok := sgrAttributes[word]; ok {
+			codes = append(codes, attr)
+			continue
+		}
+		if word == "plain" || word == "none" {
+			codes = codes[:0]
+			continue
+		}
+		code, err := parseColor(word, background)
+		if err != nil {
+			return "", err
+		}
+		codes = append(codes, code)
+		background = false
+	}
+	if background {
+		return "", fmt.Errorf("missing color after %q", "on")
+	}
+	return strings.Join(codes, ";"), nil
+}
+
+func parseColor(word string, background bool) (string, error) {
+	offset := 0
+	if background {
+		offset = sgrBackground
+	}
+	base := strings.TrimPrefix(word, "bright-")
+	if i := slices.Index(basicColors, base); i >= 0 {
+		if base != word {
+			return strconv.Itoa(sgrFgBright + offset + i), nil
+		}
+		return strconv.Itoa(sgrFgBasic + offset + i), nil
+	}
+	if word == "gray" || word == "grey" {
+		return strconv.Itoa(sgrFgBright + offset), nil
+	}
+	prefix := sgrFgExtended
+	if background {
+		prefix = sgrBgExtended
+	}
+	if n, err := strconv.Atoi(word); err == nil && n >= 0 && n <= 255 {
+		return prefix + word, nil
+	}
+	if hex, ok := strings.CutPrefix(word, "#"); ok && len(hex) == 6 {
+		if rgb, err := strconv.ParseUint(hex, 16, 24); err == nil {
+			prefix = sgrFgTrueColor
+			if background {
+				prefix = sgrBgTrueColor
+			}
+			return fmt.Sprintf("%s%d;%d;%d", prefix, rgb>>16, rgb>>8&0xff, rgb&0xff), nil
+		}
+	}
+	if strings.Trim(word, "0123456789;") == "" && !background {
+		return word, nil
+	}
+	return "", fmt.Errorf("invalid color %q", word)
+}
@@ -2,7 +2,6 @@ package entry
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 )
@@ -32,7 +31,7 @@ func addTreePrefixes(path string, entries []Entry, prefix string, depth int) ([]
 	tree := make([]Entry, 0, estimatedCapacity)
 
 	if depth == 0 {
-		fi, err := os.Stat(path)
+		fi, err := fsys.Stat(path)
 		if err != nil {
 			return nil, fmt.Errorf("accessing path %s: %w", path, err)
 		}
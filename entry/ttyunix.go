@@ -0,0 +1,17 @@
+//go:build !windows
+
+package entry
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// inForeground reports whether gaze is in the foreground process group of
+// the terminal tty. Changing the mode of a terminal from the background
+// stops the process with SIGTTOU.
+func inForeground(tty *os.File) bool {
+	pgrp, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	return err == nil && pgrp == unix.Getpgrp()
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package entry
+
+import "os"
+
+// inForeground reports false: the console has no process groups to
+// check, and it is not queried for its background color.
+func inForeground(tty *os.File) bool {
+	return false
+}
@@ -0,0 +1,332 @@
+package entry
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxLinkDepth bounds symlink resolution inside virtual trees.
+const maxLinkDepth = 40
+
+// fileSystem is the source entries are read from. Virtual trees, such as a
+// git revision, implement it so they share the grid, long and tree renderers.
+type fileSystem interface {
+	Lstat(name string) (os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
+	Readlink(name string) (string, error)
+}
+
+// openOperand resolves a command-line operand to the path to list. Git
+// revisions, container images and paths into archives are loaded into a
+// virtual tree, which is returned alongside; plain paths return a nil
+// fileSystem.
+func openOperand(arg string) (string, fileSystem, error) {
+	if rev, dir, ok := parseGitOperand(arg); ok {
+		root := strings.TrimRight(arg, "/")
+		m, err := openGitTree(root, rev, dir)
+		if err != nil {
+			return root, nil, err
+		}
+		return root, m, nil
+	}
+	path := filepath.Clean(arg)
+	if cfg.Image {
+		file, inner, ok := splitFilePath(path, func(string) bool { return true })
+		if !ok {
+			_, err := os.Stat(path)
+			if err == nil {
+				err = errors.New("not an image tarball")
+			}
+			return path, nil, err
+		}
+		img, err := openImage(path, file, inner, cfg.Layer)
+		if err != nil {
+			return path, nil, err
+		}
+		return path, img, nil
+	}
+	if archive, inner, ok := splitArchivePath(path); ok {
+		m, err := openArchive(path, archive, inner)
+		if err != nil {
+			return path, nil, err
+		}
+		return path, m, nil
+	}
+	_, err := os.Stat(path)
+	return path, nil, err
+}
+
+// preambler is implemented by virtual trees that describe themselves once,
+// before the first listing.
+type preambler interface {
+	preamble() string
+}
+
+// osFS reads entries from the local file system.
+type osFS struct{}
+
+func (osFS) Lstat(name string) (os.FileInfo, error)     { return os.Lstat(name) }
+func (osFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+func (osFS) ReadDir(name string) ([]os.DirEntry, error) { return readDir(name) }
+func (osFS) Readlink(name string) (string, error)       { return os.Readlink(name) }
+
+// readDir reads directory entries, avoiding the extra sort done by os.ReadDir.
+func readDir(path string) ([]os.DirEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.ReadDir(-1)
+}
+
+// errNoContent reports that an entry's content cannot be read, because it
+// is not a regular file or lives in a virtual tree.
+var errNoContent = errors.New("no readable content")
+
+// openEntry opens a regular file on the local file system for reading.
+func openEntry(e Entry) (*os.File, error) {
+	if _, ok := fsys.(osFS); !ok || !e.Mode().IsRegular() {
+		return nil, errNoContent
+	}
+	return os.Open(e.path)
+}
+
+// owner is implemented by the Sys value of virtual entries that carry
+// their own owner and group names.
+type owner interface {
+	owner() (string, string)
+}
+
+// entryOwner returns the owner and group of an entry. Empty names mean the
+// source does not record them.
+func entryOwner(e Entry) (string, string) {
+	if o, ok := e.Sys().(owner); ok {
+		return o.owner()
+	}
+	return userGroup(e)
+}
+
+// memNode is a file, directory or symlink held in memory.
+// It implements os.FileInfo and is its own Sys value.
+type memNode struct {
+	name     string
+	mode     os.FileMode
+	size     int64
+	modTime  time.Time
+	user     string
+	group    string
+	target   string
+	children []*memNode
+	index    map[string]*memNode
+}
+
+func (n *memNode) Name() string            { return n.name }
+func (n *memNode) Size() int64             { return n.size }
+func (n *memNode) Mode() os.FileMode       { return n.mode }
+func (n *memNode) ModTime() time.Time      { return n.modTime }
+func (n *memNode) IsDir() bool             { return n.mode.IsDir() }
+func (n *memNode) Sys() any                { return n }
+func (n *memNode) owner() (string, string) { return n.user, n.group }
+
+func (n *memNode) child(name string) *memNode {
+	return n.index[name]
+}
+
+// setChild adds c to n, replacing any existing child with the same name
+// while keeping its position.
+func (n *memNode) setChild(c *memNode) {
+	if n.index == nil {
+		n.index = make(map[string]*memNode)
+	}
+	if old, ok := n.index[c.name]; ok {
+		for i := range n.children {
+			if n.children[i] == old {
+				n.children[i] = c
+				break
+			}
+		}
+	} else {
+		n.children = append(n.children, c)
+	}
+	n.index[c.name] = c
+}
+
+func (n *memNode) removeChild(name string) {
+	old, ok := n.index[name]
+	if !ok {
+		return
+	}
+	delete(n.index, name)
+	for i := range n.children {
+		if n.children[i] == old {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+			break
+		}
+	}
+}
+
+// memFS serves an in-memory tree. Paths handed to it start with root, the
+// operand the user listed, which maps to base inside the tree.
+type memFS struct {
+	root string
+	base string
+	top  *memNode
+}
+
+func newMemFS(root, base string, modTime time.Time) *memFS {
+	return &memFS{
+		root: root,
+		base: strings.Trim(base, "/"),
+		top:  &memNode{name: filepath.Base(root), mode: os.ModeDir | 0o755, modTime: modTime},
+	}
+}
+
+// add stores n at the slash-separated path p, creating missing parent
+// directories. An existing directory keeps its children.
+func (m *memFS) add(p string, n *memNode) {
+	dir := m.mkdirAll(path.Dir(p), n.modTime)
+	n.name = path.Base(p)
+	if old := dir.child(n.name); old != nil && old.IsDir() && n.IsDir() {
+		n.children, n.index = old.children, old.index
+	}
+	dir.setChild(n)
+}
+
+// remove deletes the node at p, if any.
+func (m *memFS) remove(p string) {
+	if dir := m.node(path.Dir(p)); dir != nil {
+		dir.removeChild(path.Base(p))
+	}
+}
+
+func (m *memFS) mkdirAll(p string, modTime time.Time) *memNode {
+	n := m.top
+	for _, part := range splitPath(p) {
+		c := n.child(part)
+		if c == nil || !c.IsDir() {
+			c = &memNode{name: part, mode: os.ModeDir | 0o755, modTime: modTime}
+			n.setChild(c)
+		}
+		n = c
+	}
+	return n
+}
+
+// node returns the node at p without following symlinks.
+func (m *memFS) node(p string) *memNode {
+	n := m.top
+	for _, part := range splitPath(p) {
+		if n = n.child(part); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// resolve walks p from the top of the tree, following symlinks in
+// intermediate components and, if followLast is set, in the final one.
+func (m *memFS) resolve(p string, followLast bool, depth int) (*memNode, string, bool) {
+	if depth > maxLinkDepth {
+		return nil, "", false
+	}
+	n, cur := m.top, ""
+	parts := splitPath(p)
+	for i, part := range parts {
+		c := n.child(part)
+		if c == nil {
+			return nil, "", false
+		}
+		next := path.Join(cur, part)
+		if c.mode&os.ModeSymlink != 0 && (i < len(parts)-1 || followLast) {
+			target := c.target
+			if !path.IsAbs(target) {
+				target = path.Join(cur, target)
+			}
+			c, next, _ = m.resolve(target, true, depth+1)
+			if c == nil {
+				return nil, "", false
+			}
+		}
+		n, cur = c, next
+	}
+	return n, cur, true
+}
+
+// treePath maps a path below root to its slash-separated path in the tree.
+func (m *memFS) treePath(name string) (string, bool) {
+	name = filepath.ToSlash(name)
+	root := filepath.ToSlash(m.root)
+	if name != root && !strings.HasPrefix(name, root+"/") {
+		return "", false
+	}
+	return path.Join(m.base, strings.TrimPrefix(name, root)), true
+}
+
+func (m *memFS) lookup(op, name string, followLast bool) (*memNode, error) {
+	p, ok := m.treePath(name)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	n, _, ok := m.resolve(p, followLast, 0)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+func (m *memFS) Lstat(name string) (os.FileInfo, error) {
+	n, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	n, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (m *memFS) ReadDir(name string) ([]os.DirEntry, error) {
+	n, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries := make([]os.DirEntry, len(n.children))
+	for i, c := range n.children {
+		entries[i] = fs.FileInfoToDirEntry(c)
+	}
+	return entries, nil
+}
+
+func (m *memFS) Readlink(name string) (string, error) {
+	n, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&os.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.target, nil
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
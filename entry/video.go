@@ -0,0 +1,518 @@
+package entry
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	maxMoovSize   = 64 << 20 // Largest moov box read into memory
+	maxEBMLMaster = 16 << 20 // Largest Info or Tracks element read into memory
+	maxAVIHeader  = 1 << 20  // Largest hdrl list read into memory
+
+	mkvDefaultTimecodeScale = 1_000_000 // ns per timecode unit
+
+	mkvTrackVideo = 1
+	mkvTrackAudio = 2
+)
+
+// Matroska element IDs, with their length markers kept as in the spec.
+const (
+	ebmlHeader         = 0x1a45dfa3
+	mkvSegment         = 0x18538067
+	mkvInfo            = 0x1549a966
+	mkvTimecodeScale   = 0x2ad7b1
+	mkvDuration        = 0x4489
+	mkvTracks          = 0x1654ae6b
+	mkvTrackEntry      = 0xae
+	mkvTrackType       = 0x83
+	mkvCodecID         = 0x86
+	mkvDefaultDuration = 0x23e383
+	mkvVideo           = 0xe0
+	mkvPixelWidth      = 0xb0
+	mkvPixelHeight     = 0xba
+	mkvAudio           = 0xe1
+	mkvSamplingFreq    = 0xb5
+	mkvChannels        = 0x9f
+	mkvCluster         = 0x1f43b675
+)
+
+// mkvCodecs maps common Matroska codec IDs to their usual fourcc.
+var mkvCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "avc1",
+	"V_MPEGH/ISO/HEVC": "hvc1",
+	"V_VP8":            "vp08",
+	"V_VP9":            "vp09",
+	"V_AV1":            "av01",
+	"A_AAC":            "mp4a",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_FLAC":           "flac",
+	"A_AC3":            "ac-3",
+	"A_EAC3":           "ec-3",
+	"A_MPEG/L3":        "mp3",
+}
+
+func isMP4(magic []byte) bool {
+	if len(magic) < 8 {
+		return false
+	}
+	switch string(magic[4:8]) {
+	case "ftyp", "moov", "mdat", "free", "wide", "skip":
+		return true
+	}
+	return false
+}
+
+// parseMP4 walks the top-level boxes of an MP4 or QuickTime file, seeking
+// past media data, and reads the moov box.
+func parseMP4(f mediaFile) mediaInfo {
+	hdr := make([]byte, 16)
+	for off := int64(0); off+8 <= f.size; {
+		if _, err := f.ReadAt(hdr[:8], off); err != nil {
+			break
+		}
+		size, typ, headerLen := int64(binary.BigEndian.Uint32(hdr)), string(hdr[4:8]), int64(8)
+		switch size {
+		case 0:
+			size = f.size - off
+		case 1:
+			if _, err := f.ReadAt(hdr[8:16], off+8); err != nil {
+				return mediaInfo{}
+			}
+			size, headerLen = int64(binary.BigEndian.Uint64(hdr[8:])), 16
+		}
+		if size < headerLen {
+			break
+		}
+		if typ == "moov" {
+			if size-headerLen > maxMoovSize {
+				return mediaInfo{}
+			}
+			moov := make([]byte, size-headerLen)
+			if _, err := f.ReadAt(moov, off+headerLen); err != nil {
+				return mediaInfo{}
+			}
+			m := parseMoov(moov)
+			m.bitrate = averageBitrate(f.size, m.duration)
+			return m
+		}
+		off += size
+	}
+	return mediaInfo{}
+}
+
+// mp4Track collects the fields of one trak box.
+type mp4Track struct {
+	handler    string
+	width      int
+	height     int
+	timescale  uint32
+	duration   uint64
+	samples    uint64
+	codec      string
+	channels   int
+	sampleRate int
+}
+
+func parseMoov(moov []byte) mediaInfo {
+	var m mediaInfo
+	var video, audio *mp4Track
+	eachBox(moov, func(typ string, body []byte) {
+		switch typ {
+		case "mvhd":
+			timescale, duration := parseTimeHeader(body)
+			if timescale > 0 {
+				m.duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+			}
+		case "trak":
+			t := parseTrak(body)
+			switch {
+			case t.handler == "vide" && video == nil:
+				video = &t
+			case t.handler == "soun" && audio == nil:
+				audio = &t
+			}
+		}
+	})
+	if audio != nil {
+		m.codec = audio.codec
+		m.channels = audio.channels
+		m.sampleRate = audio.sampleRate
+	}
+	if video != nil {
+		m.codec = video.codec
+		m.width, m.height = video.width, video.height
+		if video.duration > 0 && video.timescale > 0 {
+			m.frameRate = float64(video.samples) * float64(video.timescale) / float64(video.duration)
+		}
+	}
+	return m
+}
+
+func parseTrak(trak []byte) mp4Track {
+	var t mp4Track
+	var walk func(typ string, body []byte)
+	walk = func(typ string, body []byte) {
+		switch typ {
+		case "mdia", "minf", "stbl":
+			eachBox(body, walk)
+		case "tkhd":
+			// Width and height are 16.16 fixed point at the end of the box.
+			if len(body) >= 84 {
+				end := len(body)
+				t.width = int(binary.BigEndian.Uint32(body[end-8:]) >> 16)
+				t.height = int(binary.BigEndian.Uint32(body[end-4:]) >> 16)
+			}
+		case "mdhd":
+			t.timescale, t.duration = parseTimeHeader(body)
+		case "hdlr":
+			if len(body) >= 12 {
+				t.handler = string(body[8:12])
+			}
+		case "stsd":
+			parseSampleDescription(&t, body)
+		case "stts":
+			if len(body) >= 8 {
+				n := int(binary.BigEndian.Uint32(body[4:]))
+				for i := 0; i < n && 8+i*8+8 <= len(body); i++ {
+					t.samples += uint64(binary.BigEndian.Uint32(body[8+i*8:]))
+				}
+			}
+		}
+	}
+	eachBox(trak, walk)
+	return t
+}
+
+// parseSampleDescription reads the codec fourcc of the first sample entry
+// and, for audio, its channel count and sample rate.
+func parseSampleDescription(t *mp4Track, body []byte) {
+	if len(body) < 16 {
+		return
+	}
+	entry := body[8:]
+	size := int(binary.BigEndian.Uint32(entry))
+	t.codec = strings.TrimRight(string(entry[4:8]), " \x00")
+	if size > len(entry) || size < 36 {
+		return
+	}
+	switch t.handler {
+	case "soun":
+		t.channels = int(binary.BigEndian.Uint16(entry[24:]))
+		t.sampleRate = int(binary.BigEndian.Uint32(entry[32:]) >> 16)
+	case "vide":
+		if t.width == 0 && size >= 36 {
+			t.width = int(binary.BigEndian.Uint16(entry[32:]))
+			t.height = int(binary.BigEndian.Uint16(entry[34:]))
+		}
+	}
+}
+
+// parseTimeHeader reads the timescale and duration of an mvhd or mdhd box.
+func parseTimeHeader(body []byte) (uint32, uint64) {
+	if len(body) >= 32 && body[0] == 1 {
+		return binary.BigEndian.Uint32(body[20:]), binary.BigEndian.Uint64(body[24:])
+	}
+	if len(body) >= 20 {
+		return binary.BigEndian.Uint32(body[12:]), uint64(binary.BigEndian.Uint32(body[16:]))
+	}
+	return 0, 0
+}
+
+// eachBox calls fn for every box in data.
+func eachBox(data []byte, fn func(typ string, body []byte)) {
+	for len(data) >= 8 {
+		size, headerLen := uint64(binary.BigEndian.Uint32(data)), uint64(8)
+		typ := string(data[4:8])
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size, headerLen = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			return
+		}
+		fn(typ, data[headerLen:size])
+		data = data[size:]
+	}
+}
+
+// parseMatroska reads the Info and Tracks elements of the first segment,
+// stopping at the first cluster.
+func parseMatroska(f mediaFile) mediaInfo {
+	var m mediaInfo
+	off := int64(0)
+	id, size, n := readEBMLHeader(f, off)
+	if id != ebmlHeader || n == 0 {
+		return m
+	}
+	off += int64(n) + size
+	if id, _, n = readEBMLHeader(f, off); id != mkvSegment || n == 0 {
+		return m
+	}
+	off += int64(n)
+
+	for off < f.size {
+		id, size, n := readEBMLHeader(f, off)
+		if n == 0 || id == mkvCluster || size < 0 {
+			break
+		}
+		off += int64(n)
+		if (id == mkvInfo || id == mkvTracks) && size <= maxEBMLMaster {
+			data := make([]byte, size)
+			if _, err := f.ReadAt(data, off); err != nil {
+				break
+			}
+			if id == mkvInfo {
+				parseMKVInfo(&m, data)
+			} else {
+				parseMKVTracks(&m, data)
+			}
+		}
+		off += size
+	}
+	m.bitrate = averageBitrate(f.size, m.duration)
+	return m
+}
+
+func parseMKVInfo(m *mediaInfo, data []byte) {
+	scale := uint64(mkvDefaultTimecodeScale)
+	var duration float64
+	eachEBML(data, func(id uint64, body []byte) {
+		switch id {
+		case mkvTimecodeScale:
+			scale = ebmlUint(body)
+		case mkvDuration:
+			duration = ebmlFloat(body)
+		}
+	})
+	m.duration = time.Duration(duration * float64(scale))
+}
+
+func parseMKVTracks(m *mediaInfo, data []byte) {
+	var haveVideo, haveAudio bool
+	eachEBML(data, func(id uint64, entry []byte) {
+		if id != mkvTrackEntry {
+			return
+		}
+		var t mediaInfo
+		var kind uint64
+		var frameDuration uint64
+		eachEBML(entry, func(id uint64, body []byte) {
+			switch id {
+			case mkvTrackType:
+				kind = ebmlUint(body)
+			case mkvCodecID:
+				t.codec = mkvCodec(string(body))
+			case mkvDefaultDuration:
+				frameDuration = ebmlUint(body)
+			case mkvVideo:
+				eachEBML(body, func(id uint64, v []byte) {
+					switch id {
+					case mkvPixelWidth:
+						t.width = int(ebmlUint(v))
+					case mkvPixelHeight:
+						t.height = int(ebmlUint(v))
+					}
+				})
+			case mkvAudio:
+				eachEBML(body, func(id uint64, a []byte) {
+					switch id {
+					case mkvSamplingFreq:
+						t.sampleRate = int(ebmlFloat(a))
+					case mkvChannels:
+						t.channels = int(ebmlUint(a))
+					}
+				})
+			}
+		})
+		switch {
+		case kind == mkvTrackVideo && !haveVideo:
+			haveVideo = true
+			m.codec, m.width, m.height = t.codec, t.width, t.height
+			if frameDuration > 0 {
+				m.frameRate = float64(time.Second) / float64(frameDuration)
+			}
+		case kind == mkvTrackAudio && !haveAudio:
+			haveAudio = true
+			m.sampleRate, m.channels = t.sampleRate, t.channels
+			if !haveVideo {
+				m.codec = t.codec
+			}
+		}
+	})
+}
+
+func mkvCodec(id string) string {
+	if c, ok := mkvCodecs[id]; ok {
+		return c
+	}
+	id = strings.TrimPrefix(strings.TrimPrefix(id, "V_"), "A_")
+	return strings.ToLower(id)
+}
+
+// readEBMLHeader reads an element ID and size at off. It returns the
+// number of header bytes, or 0 if none could be read. Unknown sizes are -1.
+func readEBMLHeader(f mediaFile, off int64) (uint64, int64, int) {
+	buf := make([]byte, 12)
+	n, _ := f.ReadAt(buf, off)
+	buf = buf[:n]
+	id, idLen := ebmlVint(buf, false)
+	if idLen == 0 {
+		return 0, 0, 0
+	}
+	size, sizeLen := ebmlVint(buf[idLen:], true)
+	if sizeLen == 0 {
+		return 0, 0, 0
+	}
+	if size == 1<<(7*sizeLen)-1 {
+		return id, -1, idLen + sizeLen
+	}
+	return id, int64(size), idLen + sizeLen
+}
+
+// eachEBML calls fn for every element in data.
+func eachEBML(data []byte, fn func(id uint64, body []byte)) {
+	for len(data) > 0 {
+		id, idLen := ebmlVint(data, false)
+		if idLen == 0 {
+			return
+		}
+		size, sizeLen := ebmlVint(data[idLen:], true)
+		start := idLen + sizeLen
+		if sizeLen == 0 || size > uint64(len(data)-start) {
+			return
+		}
+		fn(id, data[start:start+int(size)])
+		data = data[start+int(size):]
+	}
+}
+
+// ebmlVint decodes a variable-length integer. IDs keep their length marker;
+// sizes have it masked off.
+func ebmlVint(b []byte, mask bool) (uint64, int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+	n := 1
+	for b[0]&(0x80>>(n-1)) == 0 {
+		n++
+	}
+	if n > 8 || n > len(b) {
+		return 0, 0
+	}
+	v := uint64(b[0])
+	if mask {
+		v &= uint64(0xff >> n)
+	}
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// parseAVI reads the hdrl list of an AVI file: the main header and the
+// header and format of each stream.
+func parseAVI(f mediaFile) mediaInfo {
+	hdr := make([]byte, 12)
+	if _, err := f.ReadAt(hdr, 12); err != nil || string(hdr[:4]) != "LIST" || string(hdr[8:12]) != "hdrl" {
+		return mediaInfo{}
+	}
+	size := int64(binary.LittleEndian.Uint32(hdr[4:])) - 4
+	if size <= 0 || size > maxAVIHeader {
+		return mediaInfo{}
+	}
+	hdrl := make([]byte, size)
+	if _, err := f.ReadAt(hdrl, 24); err != nil {
+		return mediaInfo{}
+	}
+
+	var m mediaInfo
+	var haveVideo, haveAudio bool
+	eachRIFFChunk(hdrl, func(id string, body []byte) {
+		switch {
+		case id == "avih" && len(body) >= 40:
+			usPerFrame := binary.LittleEndian.Uint32(body[0:])
+			frames := binary.LittleEndian.Uint32(body[16:])
+			m.duration = time.Duration(uint64(usPerFrame)*uint64(frames)) * time.Microsecond
+			m.width = int(binary.LittleEndian.Uint32(body[32:]))
+			m.height = int(binary.LittleEndian.Uint32(body[36:]))
+		case id == "LIST" && len(body) >= 4 && string(body[:4]) == "strl":
+			var kind, handler string
+			var scale, rate uint32
+			eachRIFFChunk(body[4:], func(id string, c []byte) {
+				switch {
+				case id == "strh" && len(c) >= 28:
+					kind, handler = string(c[0:4]), string(c[4:8])
+					scale = binary.LittleEndian.Uint32(c[20:])
+					rate = binary.LittleEndian.Uint32(c[24:])
+				case id == "strf" && kind == "vids" && !haveVideo && len(c) >= 20:
+					haveVideo = true
+					m.codec = aviFourCC(string(c[16:20]), handler)
+					if scale > 0 {
+						m.frameRate = float64(rate) / float64(scale)
+					}
+				case id == "strf" && kind == "auds" && !haveAudio && len(c) >= 8:
+					haveAudio = true
+					m.channels = int(binary.LittleEndian.Uint16(c[2:]))
+					m.sampleRate = int(binary.LittleEndian.Uint32(c[4:]))
+				}
+			})
+		}
+	})
+	m.bitrate = averageBitrate(f.size, m.duration)
+	return m
+}
+
+// aviFourCC prefers the stream format's compression code over the stream
+// header's handler, which some muxers leave empty.
+func aviFourCC(compression, handler string) string {
+	for _, c := range []string{compression, handler} {
+		if c = strings.TrimRight(c, " \x00"); c != "" {
+			return strings.ToLower(c)
+		}
+	}
+	return ""
+}
+
+// eachRIFFChunk calls fn for every chunk in data.
+func eachRIFFChunk(data []byte, fn func(id string, body []byte)) {
+	for len(data) >= 8 {
+		id := string(data[:4])
+		size := int(binary.LittleEndian.Uint32(data[4:]))
+		if size > len(data)-8 {
+			return
+		}
+		fn(id, data[8:8+size])
+		next := 8 + size + size%2
+		if next > len(data) {
+			return
+		}
+		data = data[next:]
+	}
+}